@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	ErrNoUrls            = errors.New("no urls given")
+	ErrNoSearchQuery     = errors.New("no search query given")
+	ErrMissingFlagValue  = errors.New("missing value")
+	ErrUnsupportedConfig = errors.New("unsupported config command")
+)
+
+type command struct {
+	name        string
+	args        string
+	description string
+}
+
+// commands are selected by the first argument, without one BeatportDL
+// prompts for URLs and search queries.
+var commands = []command{
+	{"download", "URL|FILE.txt...", "Download the given URLs and quit"},
+	{"search", "QUERY", "Print the search results with their URLs"},
+	{"info", "URL...", "Print the metadata of the given URLs"},
+	{"config", "[options]", "Print the effective config, or every option with its flag and environment variable"},
+	{"watch", "[-daemon] [-interval D] [-seed] [FILE]", "Download new releases of the watchlist"},
+	{"sync", "URL|FILE.txt...", "Keep playlist and chart folders identical to Beatport"},
+	{"scan", "[DIR...]", "Export existing downloads to the DJ software collections"},
+	{"retag", "[-n] [DIR...]", "Tag existing downloads again"},
+	{"reorganize", "[-n] [-undo LOG] [DIR...]", "Move existing downloads to their current template paths"},
+}
+
+func isCommand(name string) bool {
+	for _, c := range commands {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: beatportdl [-q] [--config FILE] [--profile NAME] [--option value...] [command] [args]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-11s %-40s %s\n", c.name, c.args, c.description)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\nEvery config option can be set with --option-name or BEATPORTDL_OPTION_NAME,\n"+
+		"see \"beatportdl config options\". Flags take precedence over environment\n"+
+		"variables, which take precedence over the selected profile, the config file\n"+
+		"and the defaults.\n")
+}
+
+// configSelection is the config file and the profile chosen with --config
+// and --profile, or BEATPORTDL_CONFIG and BEATPORTDL_PROFILE.
+type configSelection struct {
+	Path    string
+	Profile string
+}
+
+// withEnv fills in what the flags left unset from the environment.
+func (s configSelection) withEnv(lookup func(string) (string, bool)) configSelection {
+	if value, ok := lookup(config.EnvPrefix + "CONFIG"); ok && s.Path == "" {
+		s.Path = value
+	}
+	if value, ok := lookup(config.EnvPrefix + "PROFILE"); ok && s.Profile == "" {
+		s.Profile = value
+	}
+	return s
+}
+
+// extractConfigFlags takes the config option flags, --config and --profile
+// out of args, wherever they are, so that every command accepts them. The
+// other arguments are returned in their order.
+func extractConfigFlags(args []string) (configSelection, config.Overrides, []string, error) {
+	var selection configSelection
+	overrides := make(config.Overrides)
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		option, ok := config.LookupOption(name)
+		isSelection := name == "config" || name == "profile"
+		if !ok && !isSelection {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if option.IsBool() && !isSelection {
+				value = "true"
+			} else if i+1 < len(args) {
+				i++
+				value = args[i]
+			} else {
+				return configSelection{}, nil, nil, fmt.Errorf("flag --%s: %w", name, ErrMissingFlagValue)
+			}
+		}
+		switch name {
+		case "config":
+			selection.Path = value
+		case "profile":
+			selection.Profile = value
+		default:
+			overrides[option.Key] = value
+		}
+	}
+	return selection, overrides, rest, nil
+}
+
+// printConfig prints the effective config as YAML, or with "options" the
+// flag and environment variable of every option.
+func (app *application) printConfig(args []string) error {
+	if len(args) > 0 && args[0] == "options" {
+		aliases := make(map[string]string)
+		for alias, key := range config.FlagAliases {
+			aliases[key] = alias
+		}
+		fmt.Printf("%-30s %-10s %-38s %s\n", "FLAG", "TYPE", "ENVIRONMENT", "ALIAS")
+		for _, option := range config.Options() {
+			alias := ""
+			if a, ok := aliases[option.Key]; ok {
+				alias = "--" + a
+			}
+			fmt.Printf("%-30s %-10s %-38s %s\n", "--"+option.Flag, option.Type, option.Env, alias)
+		}
+		return nil
+	} else if len(args) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnsupportedConfig, args[0])
+	}
+
+	cfg := *app.config
+	if cfg.Password != "" {
+		cfg.Password = "********"
+	}
+	data, err := yaml.Marshal(&cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// searchCommand prints the search results for a query, one per line with its
+// type and URL, so that they can be passed on to download.
+func (app *application) searchCommand(args []string) error {
+	query := strings.Join(args, " ")
+	if query == "" {
+		return ErrNoSearchQuery
+	}
+	results, err := app.bp.SearchContext(app.ctx, query)
+	if err != nil {
+		return err
+	}
+
+	for _, track := range results.Tracks {
+		fmt.Printf("track\t%s\t%s - %s (%s)\n",
+			track.StoreUrl(),
+			track.Artists.Display(app.config.ArtistsLimit, app.config.ArtistsShortForm),
+			track.Name.String(), track.MixName.String(),
+		)
+	}
+	for _, release := range results.Releases {
+		fmt.Printf("release\t%s\t%s - %s [%s]\n",
+			release.StoreUrl(),
+			release.Artists.Display(app.config.ArtistsLimit, app.config.ArtistsShortForm),
+			release.Name.String(), release.Label.Name,
+		)
+	}
+	for _, label := range results.Labels {
+		fmt.Printf("label\t%s\t%s\n", label.StoreUrl(), label.Name)
+	}
+	return nil
+}
+
+// info prints the metadata of the given URLs without downloading anything.
+func (app *application) info(args []string) error {
+	if len(args) == 0 {
+		return ErrNoUrls
+	}
+	for i, url := range args {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := app.printInfo(url); err != nil {
+			app.errorLogWrapper(url, "info", err)
+		}
+	}
+	return nil
+}
+
+func (app *application) printInfo(url string) error {
+	link, err := app.bp.ParseUrl(url)
+	if err != nil {
+		return err
+	}
+
+	var fields [][2]string
+	switch link.Type {
+	case beatport.TrackLink:
+		track, err := app.bp.GetTrackContext(app.ctx, link.ID)
+		if err != nil {
+			return err
+		}
+		release, err := app.bp.GetReleaseContext(app.ctx, track.Release.ID)
+		if err != nil {
+			return err
+		}
+		fields = [][2]string{
+			{"Track", fmt.Sprintf("%s (%s)", track.Name.String(), track.MixName.String())},
+			{"Artists", track.Artists.Display(0, "")},
+			{"Remixers", track.Remixers.Display(0, "")},
+			{"Release", release.Name.String()},
+			{"Label", release.Label.Name},
+			{"Catalog number", release.CatalogNumber.String()},
+			{"Date", release.Date},
+			{"Genre", track.GenreWithSubgenre(" | ")},
+			{"Key", track.Key.Display(app.config.KeySystem)},
+			{"BPM", fmt.Sprint(track.BPM)},
+			{"Length", track.Length},
+			{"ISRC", track.ISRC},
+			{"URL", track.StoreUrl()},
+		}
+	case beatport.ReleaseLink:
+		release, err := app.bp.GetReleaseContext(app.ctx, link.ID)
+		if err != nil {
+			return err
+		}
+		fields = [][2]string{
+			{"Release", release.Name.String()},
+			{"Artists", release.Artists.Display(0, "")},
+			{"Label", release.Label.Name},
+			{"Catalog number", release.CatalogNumber.String()},
+			{"Date", release.Date},
+			{"Tracks", fmt.Sprint(release.TrackCount)},
+			{"UPC", release.UPC},
+			{"URL", release.StoreUrl()},
+		}
+	case beatport.PlaylistLink:
+		playlist, err := app.bp.GetPlaylistContext(app.ctx, link.ID)
+		if err != nil {
+			return err
+		}
+		fields = [][2]string{
+			{"Playlist", playlist.Name},
+			{"Genres", strings.Join(playlist.Genres, ", ")},
+			{"Tracks", fmt.Sprint(playlist.TrackCount)},
+			{"Length", playlist.LengthMs.Display()},
+			{"Updated", playlist.UpdatedDate.Format("2006-01-02")},
+		}
+	case beatport.ChartLink:
+		chart, err := app.bp.GetChartContext(app.ctx, link.ID)
+		if err != nil {
+			return err
+		}
+		fields = [][2]string{
+			{"Chart", chart.Name},
+			{"Curator", chart.Person.OwnerName},
+			{"Tracks", fmt.Sprint(chart.TrackCount)},
+			{"Published", chart.PublishDate.Format("2006-01-02")},
+		}
+	case beatport.LabelLink:
+		label, err := app.bp.GetLabelContext(app.ctx, link.ID)
+		if err != nil {
+			return err
+		}
+		fields = [][2]string{
+			{"Label", label.Name},
+			{"Updated", label.Updated.Format("2006-01-02")},
+			{"URL", label.StoreUrl()},
+		}
+	case beatport.ArtistLink:
+		artist, err := app.bp.GetArtistContext(app.ctx, link.ID)
+		if err != nil {
+			return err
+		}
+		fields = [][2]string{
+			{"Artist", artist.Name},
+		}
+	default:
+		return ErrUnsupportedLinkType
+	}
+
+	for _, field := range fields {
+		if field[1] != "" {
+			fmt.Printf("%-15s %s\n", field[0]+":", field[1])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestExtractConfigFlags(t *testing.T) {
+	args := []string{
+		"-q", "download", "--quality", "high", "--dir=/mnt/x", "--fix-tags=false",
+		"--keep-cover", "--profile", "promo", "https://www.beatport.com/release/x/1", "-n",
+		"--config=/etc/beatportdl.yml", "--", "--quality",
+	}
+	selection, overrides, rest, err := extractConfigFlags(args)
+	if err != nil {
+		t.Fatalf("extractConfigFlags() failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"quality":             "high",
+		"downloads_directory": "/mnt/x",
+		"fix_tags":            "false",
+		"keep_cover":          "true",
+	}
+	if len(overrides) != len(expected) {
+		t.Errorf("overrides = %v, want %v", overrides, expected)
+	}
+	for key, value := range expected {
+		if overrides[key] != value {
+			t.Errorf("overrides[%s] = %q, want %q", key, overrides[key], value)
+		}
+	}
+
+	expectedRest := []string{"-q", "download", "https://www.beatport.com/release/x/1", "-n", "--", "--quality"}
+	if !slices.Equal(rest, expectedRest) {
+		t.Errorf("rest = %v, want %v", rest, expectedRest)
+	}
+
+	expectedSelection := configSelection{Path: "/etc/beatportdl.yml", Profile: "promo"}
+	if selection != expectedSelection {
+		t.Errorf("selection = %+v, want %+v", selection, expectedSelection)
+	}
+	env := func(key string) (string, bool) {
+		value, ok := map[string]string{"BEATPORTDL_CONFIG": "/tmp/x.yml", "BEATPORTDL_PROFILE": "lossless"}[key]
+		return value, ok
+	}
+	if got := selection.withEnv(env); got != expectedSelection {
+		t.Errorf("withEnv() = %+v, want the flags to take precedence", got)
+	}
+	if got := (configSelection{}).withEnv(env); got.Path != "/tmp/x.yml" || got.Profile != "lossless" {
+		t.Errorf("withEnv() = %+v, want the environment values", got)
+	}
+
+	if _, _, _, err := extractConfigFlags([]string{"--template"}); !errors.Is(err, ErrMissingFlagValue) {
+		t.Errorf("extractConfigFlags() error = %v, want %v", err, ErrMissingFlagValue)
+	}
+}
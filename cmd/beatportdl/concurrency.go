@@ -14,7 +14,7 @@ func (app *application) globalWorker(fn func()) {
 		defer app.semRelease(app.globalSem)
 		defer func() {
 			if err := recover(); err != nil {
-				fmt.Printf(fmt.Errorf("%s", err).Error())
+				app.LogError("panic", fmt.Errorf("%v", err))
 			}
 		}()
 		fn()
@@ -37,7 +37,7 @@ func (app *application) downloadWorker(wg *sync.WaitGroup, fn func()) {
 
 		defer func() {
 			if err := recover(); err != nil {
-				fmt.Printf(fmt.Errorf("%s", err).Error())
+				app.LogError("panic", fmt.Errorf("%v", err))
 			}
 		}()
 		fn()
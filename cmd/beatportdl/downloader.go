@@ -11,17 +11,45 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unspok3n/beatportdl/config"
 	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/history"
+	"unspok3n/beatportdl/internal/remux"
 	"unspok3n/beatportdl/internal/taglib"
+	"unspok3n/beatportdl/internal/validator"
 )
 
 func (app *application) errorLogWrapper(url, step string, err error) {
-	app.LogError(fmt.Sprintf("[%s] %s", url, step), err)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	app.report.addFailed(url)
+	app.logError(url, step, err)
+}
+
+// postProcessLogWrapper reports an error of a step after the tracks of a URL
+// were saved, such as writing its playlist or exporting the collections.
+// Unlike errorLogWrapper it doesn't count the URL as failed, downloading it
+// again wouldn't help.
+func (app *application) postProcessLogWrapper(url, step string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	app.logError(url, step, err)
+}
+
+// retryLogWrapper reports a failed attempt that is going to be retried, as a
+// retry event in JSON output mode. Unlike errorLogWrapper it doesn't count
+// the URL as failed.
+func (app *application) retryLogWrapper(url string, attempt int, delay time.Duration, err error) {
+	app.emit(event{Event: eventRetry, URL: url, Attempt: attempt, Error: err.Error()})
+	step := fmt.Sprintf("attempt %d/%d, retrying in %s", attempt, app.config.RetryAttempts, delay.Round(time.Millisecond))
+	app.log().Warn(step, "url", url, "error", err)
 }
 
 func (app *application) infoLogWrapper(url, message string) {
-	app.LogInfo(fmt.Sprintf("[%s] %s", url, message))
+	app.log().Info(message, "url", url)
 }
 
 func (app *application) createDirectory(baseDir string, subDir ...string) (string, error) {
@@ -35,6 +63,12 @@ type DownloadsDirectoryEntity interface {
 }
 
 func (app *application) setupDownloadsDirectory(baseDir string, entity DownloadsDirectoryEntity) (string, error) {
+	return app.createDirectory(app.downloadsDirectoryPath(baseDir, entity))
+}
+
+// downloadsDirectoryPath returns the directory inside baseDir that the tracks
+// of entity are saved to.
+func (app *application) downloadsDirectoryPath(baseDir string, entity DownloadsDirectoryEntity) string {
 	if app.config.SortByContext {
 		var subDir string
 		switch castedEntity := entity.(type) {
@@ -48,9 +82,6 @@ func (app *application) setupDownloadsDirectory(baseDir string, entity Downloads
 					TrackNumberPadding: app.config.TrackNumberPadding,
 				},
 			)
-			if app.config.SortByLabel && entity != nil {
-				baseDir = filepath.Join(baseDir, castedEntity.Label.Name)
-			}
 		case *beatport.Playlist:
 			subDir = castedEntity.DirectoryName(
 				beatport.NamingPreferences{
@@ -84,12 +115,12 @@ func (app *application) setupDownloadsDirectory(baseDir string, entity Downloads
 		}
 		baseDir = filepath.Join(baseDir, subDir)
 	}
-	return app.createDirectory(baseDir)
+	return baseDir
 }
 
 func (app *application) requireCover(respectFixTags, respectKeepCover bool) bool {
 	fixTags := respectFixTags && app.config.FixTags &&
-		(app.config.CoverSize != config.DefaultCoverSize || app.config.Quality != "lossless")
+		(app.config.CoverSize != config.DefaultCoverSize || app.config.Quality != "lossless" || app.config.Format != "original")
 	keepCover := respectKeepCover && app.config.SortByContext && app.config.KeepCover
 	return fixTags || keepCover
 }
@@ -97,9 +128,9 @@ func (app *application) requireCover(respectFixTags, respectKeepCover bool) bool
 func (app *application) downloadCover(image beatport.Image, downloadsDir string) (string, error) {
 	coverUrl := image.FormattedUrl(app.config.CoverSize)
 	coverPath := filepath.Join(downloadsDir, uuid.New().String())
-	err := app.downloadFile(coverUrl, coverPath, "")
+	err := app.downloadFile(app.ctx, coverUrl, coverPath, nil)
 	if err != nil {
-		os.Remove(coverPath)
+		os.Remove(coverPath + partFileSuffix)
 		return "", err
 	}
 	return coverPath, nil
@@ -110,7 +141,7 @@ func (app *application) handleCoverFile(path string) error {
 		return nil
 	}
 	if app.config.KeepCover && app.config.SortByContext {
-		newPath := filepath.Dir(path) + "/cover.jpg"
+		newPath := filepath.Join(filepath.Dir(path), coverFilename)
 		if err := os.Rename(path, newPath); err != nil {
 			return err
 		}
@@ -120,11 +151,43 @@ func (app *application) handleCoverFile(path string) error {
 	return nil
 }
 
+// previewFileSuffix marks the files of preview clips, so that they aren't
+// mistaken for full downloads and the other way around.
+const previewFileSuffix = " (Preview)"
+
+// saveFilename returns the file name of a track downloaded in quality,
+// without the extension.
+func (app *application) saveFilename(track *beatport.Track, quality string) string {
+	fileName := app.trackFilename(track)
+	if quality == "preview" {
+		fileName += previewFileSuffix
+	}
+	return fileName
+}
+
+// trackFilename returns the file name of a track without the extension.
+func (app *application) trackFilename(track *beatport.Track) string {
+	return track.Filename(
+		beatport.NamingPreferences{
+			Template:           app.config.TrackFileTemplate,
+			Whitespace:         app.config.WhitespaceCharacter,
+			ArtistsLimit:       app.config.ArtistsLimit,
+			ArtistsShortForm:   app.config.ArtistsShortForm,
+			TrackNumberPadding: app.config.TrackNumberPadding,
+			KeySystem:          app.config.KeySystem,
+		},
+	)
+}
+
 var (
 	ErrTrackFileExists = errors.New("file already exists")
+	ErrTrackSkipped    = errors.New("track skipped")
+	ErrTrackUpdate     = errors.New("track exists, updating tags")
 )
 
-func (app *application) saveTrack(track *beatport.Track, directory string, quality string) (string, error) {
+// saveTrack downloads a track and returns the location of its file and the
+// quality it was saved in.
+func (app *application) saveTrack(track *beatport.Track, directory string, quality string) (string, string, error) {
 	var fileExtension string
 	var displayQuality string
 
@@ -132,18 +195,21 @@ func (app *application) saveTrack(track *beatport.Track, directory string, quali
 	var download *beatport.TrackDownload
 
 	switch app.config.Quality {
-	case "medium-hls":
-		trackStream, err := app.bp.StreamTrack(track.ID)
+	case "medium-hls", "preview":
+		trackStream, err := app.bp.StreamTrackContext(app.ctx, track.ID)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 		fileExtension = ".m4a"
 		displayQuality = "AAC 128kbps - HLS"
+		if app.config.Quality == "preview" {
+			displayQuality = "AAC 128kbps - Preview"
+		}
 		stream = trackStream
 	default:
-		trackDownload, err := app.bp.DownloadTrack(track.ID, quality)
+		trackDownload, err := app.bp.DownloadTrackContext(app.ctx, track.ID, quality)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 		switch trackDownload.StreamQuality {
 		case ".128k.aac.mp4":
@@ -156,109 +222,152 @@ func (app *application) saveTrack(track *beatport.Track, directory string, quali
 			fileExtension = ".flac"
 			displayQuality = "FLAC"
 		default:
-			return "", fmt.Errorf("invalid stream quality: %s", trackDownload.StreamQuality)
+			return "", "", fmt.Errorf("invalid stream quality: %s", trackDownload.StreamQuality)
 		}
 		download = trackDownload
 	}
 
-	fileName := track.Filename(
-		beatport.NamingPreferences{
-			Template:           app.config.TrackFileTemplate,
-			Whitespace:         app.config.WhitespaceCharacter,
-			ArtistsLimit:       app.config.ArtistsLimit,
-			ArtistsShortForm:   app.config.ArtistsShortForm,
-			TrackNumberPadding: app.config.TrackNumberPadding,
-			KeySystem:          app.config.KeySystem,
-		},
-	)
+	// The download is transcoded from sourcePath to filePath if an output
+	// format is set, otherwise both are the same file
+	sourceExtension := fileExtension
+	if app.config.Format != "original" {
+		fileExtension = "." + app.config.Format
+		displayQuality = fmt.Sprintf("%s > %s", displayQuality, strings.ToUpper(app.config.Format))
+	}
+
+	fileName := app.saveFilename(track, quality)
 	filePath := fmt.Sprintf("%s/%s%s", directory, fileName, fileExtension)
-	if _, err := os.Stat(filePath); err == nil {
-		app.activeFilesMutex.RLock()
-		_, exists := app.activeFiles[filePath]
-		app.activeFilesMutex.RUnlock()
-
-		if exists {
-			i := 1
-			for {
-				filePath = fmt.Sprintf("%s/%s (%d)%s", directory, fileName, i, fileExtension)
-				if _, err := os.Stat(filePath); os.IsNotExist(err) {
-					break
-				}
-				i++
-			}
-		} else {
-			switch app.config.TrackExists {
-			case "skip":
-				return "", nil
-			case "update":
-				app.infoLogWrapper(track.StoreUrl(), "updating tags")
-				return filePath, nil
-			case "error":
-				return "", ErrTrackFileExists
-			}
-		}
+	// the track file template may nest directories as well
+	if err := CreateDirectory(filepath.Dir(filePath)); err != nil {
+		return "", "", err
 	}
 
 	app.activeFilesMutex.Lock()
+	if _, active := app.activeFiles[filePath]; active {
+		i := 1
+		for {
+			filePath = fmt.Sprintf("%s/%s (%d)%s", directory, fileName, i, fileExtension)
+			_, active := app.activeFiles[filePath]
+			if !active && !fileExists(filePath) && !fileExists(filePath+partFileSuffix) {
+				break
+			}
+			i++
+		}
+	} else if fileExists(filePath) {
+		switch app.config.TrackExists {
+		case "skip":
+			app.activeFilesMutex.Unlock()
+			return filePath, displayQuality, ErrTrackSkipped
+		case "update":
+			app.activeFilesMutex.Unlock()
+			app.infoLogWrapper(track.StoreUrl(), "updating tags")
+			return filePath, displayQuality, ErrTrackUpdate
+		case "error":
+			app.activeFilesMutex.Unlock()
+			return "", "", ErrTrackFileExists
+		}
+	} else if fileExists(filePath + partFileSuffix) {
+		app.infoLogWrapper(track.StoreUrl(), "resuming download")
+	}
 	app.activeFiles[filePath] = struct{}{}
 	app.activeFilesMutex.Unlock()
 
-	var prefix string
+	var progress *downloadProgress
 	infoDisplay := fmt.Sprintf("%s (%s) [%s]", track.Name.String(), track.MixName.String(), displayQuality)
-	if app.config.ShowProgress {
-		prefix = infoDisplay
+	if app.config.ShowProgress || app.events != nil {
+		progress = &downloadProgress{name: infoDisplay, url: track.StoreUrl()}
 	} else {
-		fmt.Println("Downloading " + infoDisplay)
+		app.LogInfo("Downloading " + infoDisplay)
+	}
+
+	sourcePath := filePath
+	if sourceExtension != fileExtension {
+		sourcePath = filePath + sourceExtension
 	}
 
 	if download != nil {
-		if err := app.downloadFile(download.Location, filePath, prefix); err != nil {
-			os.Remove(filePath)
-			return "", err
+		if err := app.downloadFile(app.ctx, download.Location, sourcePath, progress); err != nil {
+			return "", "", err
 		}
 	} else if stream != nil {
-		segments, key, err := getStreamSegments(stream.Url)
+		segments, err := app.getStreamSegments(app.ctx, stream.Url)
 		if err != nil {
-			return "", fmt.Errorf("get stream segments: %v", err)
+			return "", "", fmt.Errorf("get stream segments: %v", err)
+		}
+		var clip remux.Clip
+		if app.config.Quality == "preview" {
+			segments, clip, err = previewSegments(segments, stream)
+			if err != nil {
+				return "", "", err
+			}
 		}
-		segmentsFile, err := app.downloadSegments(directory, *segments, *key, prefix)
+		segmentsFile, err := app.downloadSegments(app.ctx, directory, segments, progress)
 		defer os.Remove(segmentsFile)
 		if err != nil {
-			return "", fmt.Errorf("download segments: %v", err)
+			return "", "", fmt.Errorf("download segments: %v", err)
 		}
-		if err := remuxToM4A(segmentsFile, filePath); err != nil {
-			os.Remove(filePath)
-			return "", fmt.Errorf("remux to m4a: %v", err)
+		if err := remuxToM4A(segmentsFile, sourcePath, clip); err != nil {
+			os.Remove(sourcePath)
+			return "", "", fmt.Errorf("remux to m4a: %v", err)
 		}
 	}
 
-	if !app.config.ShowProgress {
-		fmt.Printf("Finished downloading %s\n", infoDisplay)
+	if sourcePath != filePath {
+		err := app.encoder.Encode(app.ctx, sourcePath, filePath+partFileSuffix, app.config.Format)
+		os.Remove(sourcePath)
+		if err == nil {
+			err = os.Rename(filePath+partFileSuffix, filePath)
+		}
+		if err != nil {
+			os.Remove(filePath + partFileSuffix)
+			return "", "", fmt.Errorf("transcode: %v", err)
+		}
+	}
+
+	if progress == nil {
+		app.LogInfo("Finished downloading " + infoDisplay)
 	}
 
-	return filePath, nil
+	return filePath, displayQuality, nil
 }
 
 const (
 	rawTagSuffix = "_raw"
 )
 
-func (app *application) tagTrack(location string, track *beatport.Track, coverPath string) error {
-	fileExt := filepath.Ext(location)
-	if !app.config.FixTags {
-		return nil
+// tagMappings returns the tag mappings for a file extension and whether they
+// are id3v2 frame mappings. MP3, AIFF and WAV files fall back to the id3v2
+// mappings unless the config has a section for the container.
+func (app *application) tagMappings(fileExt string) (map[string]string, bool) {
+	format := strings.TrimPrefix(fileExt, ".")
+	if mappings, ok := app.config.TagMappings[format]; ok {
+		return mappings, false
 	}
-	file, err := taglib.Read(location)
-	if err != nil {
-		return err
+	if validator.PermittedValue(format, config.ID3v2TagMappingFormats...) {
+		return app.config.TagMappings["id3v2"], true
 	}
-	defer file.Close()
+	return nil, false
+}
 
+// readTag returns the value of a mapped tag. Raw M4A items are read from
+// their freeform atom.
+func readTag(file *taglib.File, property string, id3v2 bool) string {
+	if id3v2 {
+		return file.GetID3v2Frame(property)
+	}
+	if raw, found := strings.CutSuffix(property, rawTagSuffix); found {
+		return file.GetItemMp4(raw)
+	}
+	return file.GetProperty(property)
+}
+
+// tagValues returns the values of the tag mapping fields for a track.
+func (app *application) tagValues(track *beatport.Track) map[string]string {
 	subgenre := ""
 	if track.Subgenre != nil {
 		subgenre = track.Subgenre.Name
 	}
-	mappingValues := map[string]string{
+	return map[string]string{
 		"track_id":       strconv.Itoa(int(track.ID)),
 		"track_url":      track.StoreUrl(),
 		"track_name":     fmt.Sprintf("%s (%s)", track.Name.String(), track.MixName.String()),
@@ -313,19 +422,49 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 		"release_label":          track.Release.Label.Name,
 		"release_label_url":      track.Release.Label.StoreUrl(),
 	}
+}
 
+func (app *application) tagTrack(location string, track *beatport.Track, coverPath string) error {
+	return app.writeTags(location, track, coverPath, false)
+}
+
+// clearTags removes every tag of the file.
+func clearTags(file *taglib.File, fileExt string, id3v2 bool) error {
 	if fileExt == ".m4a" {
-		if err = file.StripMp4(); err != nil {
-			return err
-		}
-	} else {
-		existingTags, err := file.PropertyKeys()
-		if err != nil {
-			return fmt.Errorf("read existing tags: %v", err)
-		}
+		return file.StripMp4()
+	} else if id3v2 {
+		return file.ClearID3v2()
+	}
+	existingTags, err := file.PropertyKeys()
+	if err != nil {
+		return fmt.Errorf("read existing tags: %v", err)
+	}
+	for _, tag := range existingTags {
+		file.SetProperty(tag, nil)
+	}
+	return nil
+}
+
+// writeTags writes the mapped tags of track to the file at location. Unless
+// keepUnmapped is set, all other tags of the file are removed first, otherwise
+// they are left alone and mapped tags without a value are removed.
+func (app *application) writeTags(location string, track *beatport.Track, coverPath string, keepUnmapped bool) error {
+	fileExt := filepath.Ext(location)
+	if !app.config.FixTags {
+		return nil
+	}
+	file, err := taglib.Read(location)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-		for _, tag := range existingTags {
-			file.SetProperty(tag, nil)
+	mappingValues := app.tagValues(track)
+	mappings, id3v2 := app.tagMappings(fileExt)
+
+	if !keepUnmapped {
+		if err := clearTags(file, fileExt, id3v2); err != nil {
+			return err
 		}
 	}
 
@@ -334,6 +473,8 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 			value := mappingValues[field]
 			if value != "" {
 				file.SetProperty(property, &value)
+			} else if keepUnmapped {
+				file.SetProperty(property, nil)
 			}
 		}
 	} else if fileExt == ".m4a" {
@@ -341,14 +482,18 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 
 		for field, property := range app.config.TagMappings["m4a"] {
 			if strings.HasSuffix(property, rawTagSuffix) {
+				property = strings.TrimSuffix(property, rawTagSuffix)
 				if mappingValues[field] != "" {
-					property = strings.TrimSuffix(property, rawTagSuffix)
 					rawTags[property] = mappingValues[field]
+				} else if keepUnmapped {
+					file.RemoveItemMp4(property)
 				}
 			} else {
 				value := mappingValues[field]
 				if value != "" {
 					file.SetProperty(property, &value)
+				} else if keepUnmapped {
+					file.SetProperty(property, nil)
 				}
 			}
 		}
@@ -356,9 +501,30 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 		for tag, value := range rawTags {
 			file.SetItemMp4(tag, value)
 		}
+	} else if id3v2 {
+		for field, frame := range mappings {
+			if value := mappingValues[field]; value != "" {
+				if err := file.SetID3v2Frame(frame, value); err != nil {
+					return err
+				}
+			} else if keepUnmapped {
+				if err := file.RemoveID3v2Frame(frame); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		for field, property := range mappings {
+			value := mappingValues[field]
+			if value != "" {
+				file.SetProperty(property, &value)
+			} else if keepUnmapped {
+				file.SetProperty(property, nil)
+			}
+		}
 	}
 
-	if coverPath != "" && (app.config.CoverSize != config.DefaultCoverSize || fileExt == ".m4a") {
+	if coverPath != "" && (app.config.CoverSize != config.DefaultCoverSize || fileExt != ".flac") {
 		data, err := os.ReadFile(coverPath)
 		if err != nil {
 			return err
@@ -370,7 +536,12 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 			Data:        data,
 			Size:        uint(len(data)),
 		}
-		if err := file.SetPicture(&picture); err != nil {
+		if id3v2 {
+			err = file.SetID3v2Picture(&picture)
+		} else {
+			err = file.SetPicture(&picture)
+		}
+		if err != nil {
 			return err
 		}
 	}
@@ -382,15 +553,81 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 	return nil
 }
 
-func (app *application) handleTrack(track *beatport.Track, downloadsDir string, coverPath string) error {
-	location, err := app.saveTrack(track, downloadsDir, app.config.Quality)
-	if err != nil {
-		return fmt.Errorf("save track: %v", err)
+// historyFormat returns the output format a download is recorded with in the
+// history, empty for the original file.
+func (app *application) historyFormat() string {
+	if app.config.Format == "original" {
+		return ""
 	}
-	if err = app.tagTrack(location, track, coverPath); err != nil && location != "" {
-		return fmt.Errorf("tag track: %v", err)
+	return app.config.Format
+}
+
+// handleTrack saves and tags a track and returns the location of its file,
+// which is also set when an existing file was skipped.
+func (app *application) handleTrack(track *beatport.Track, downloadsDir string, coverPath string) (string, error) {
+	app.log().Debug("track resolved", "url", track.StoreUrl(), "track_id", track.ID)
+	app.emit(event{
+		Event:   eventTrackResolved,
+		URL:     track.StoreUrl(),
+		TrackID: track.ID,
+		Name:    fmt.Sprintf("%s (%s)", track.Name.String(), track.MixName.String()),
+		Artists: track.Artists.Display(0, ""),
+		Release: track.Release.Name.String(),
+	})
+	// A track from the history is downloaded again if its file is gone
+	entry, inHistory := app.history.Lookup(track.ID, app.config.Quality, app.historyFormat())
+	inHistory = inHistory && app.config.TrackExists != "overwrite" && !app.syncMode && fileExists(entry.Path)
+	if inHistory && app.config.TrackExists != "update" {
+		app.emit(event{Event: eventTrackSkipped, URL: track.StoreUrl(), TrackID: track.ID, Path: entry.Path, Reason: skipReasonHistory})
+		app.report.addSkipped(skipReasonHistory)
+		app.recordTrack(track, entry.Path)
+		return entry.Path, nil
 	}
-	return nil
+
+	var location, quality string
+	var updated bool
+	if inHistory {
+		app.infoLogWrapper(track.StoreUrl(), "updating tags")
+		location, updated = entry.Path, true
+	} else {
+		var err error
+		location, quality, err = app.saveTrack(track, downloadsDir, app.config.Quality)
+		updated = errors.Is(err, ErrTrackUpdate)
+		if errors.Is(err, ErrTrackSkipped) {
+			app.emit(event{Event: eventTrackSkipped, URL: track.StoreUrl(), TrackID: track.ID, Path: location, Reason: skipReasonFileExists})
+			app.report.addSkipped(skipReasonFileExists)
+			app.recordTrack(track, location)
+			return location, nil
+		} else if err != nil && !updated {
+			return "", fmt.Errorf("save track: %v", err)
+		}
+	}
+	if err := app.tagTrack(location, track, coverPath); err != nil {
+		return "", fmt.Errorf("tag track: %v", err)
+	}
+	if app.history != nil {
+		entry, err := history.NewEntry(track.ID, app.config.Quality, app.historyFormat(), location)
+		if err != nil {
+			return "", fmt.Errorf("record history: %v", err)
+		}
+		if err := app.history.Add(entry); err != nil {
+			return "", fmt.Errorf("record history: %v", err)
+		}
+	}
+	app.recordTrack(track, location)
+
+	var size int64
+	if info, err := os.Stat(location); err == nil {
+		size = info.Size()
+	}
+	if updated {
+		app.report.addUpdated()
+	} else {
+		app.report.addDownloaded(size)
+	}
+	app.log().Debug("track saved", "url", track.StoreUrl(), "track_id", track.ID, "path", location, "quality", quality, "size", size)
+	app.emit(event{Event: eventTrackSaved, URL: track.StoreUrl(), TrackID: track.ID, Path: location, Quality: quality, Size: size})
+	return location, nil
 }
 
 func (app *application) cleanup(downloadsDir string) {
@@ -400,14 +637,15 @@ func (app *application) cleanup(downloadsDir string) {
 }
 
 func ForPaginated[T any](
+	ctx context.Context,
 	entityId int64,
 	params string,
-	fetchPage func(id int64, page int, params string) (results *beatport.Paginated[T], err error),
+	fetchPage func(ctx context.Context, id int64, page int, params string) (results *beatport.Paginated[T], err error),
 	processItem func(item T, i int) error,
 ) error {
 	page := 1
 	for {
-		paginated, err := fetchPage(entityId, page, params)
+		paginated, err := fetchPage(ctx, entityId, page, params)
 		if err != nil {
 			return fmt.Errorf("fetch page: %w", err)
 		}
@@ -427,12 +665,18 @@ func ForPaginated[T any](
 }
 
 func (app *application) handleUrl(url string) {
+	app.emit(event{Event: eventUrlStarted, URL: url})
 	link, err := app.bp.ParseUrl(url)
 	if err != nil {
 		app.errorLogWrapper(url, "parse url", err)
 		return
 	}
 
+	if app.syncMode && link.Type != beatport.PlaylistLink && link.Type != beatport.ChartLink {
+		app.errorLogWrapper(url, "sync", ErrUnsupportedSyncLink)
+		return
+	}
+
 	switch link.Type {
 	case beatport.TrackLink:
 		app.handleTrackLink(link)
@@ -452,13 +696,13 @@ func (app *application) handleUrl(url string) {
 }
 
 func (app *application) handleTrackLink(link *beatport.Link) {
-	track, err := app.bp.GetTrack(link.ID)
+	track, err := app.bp.GetTrackContext(app.ctx, link.ID)
 	if err != nil {
 		app.errorLogWrapper(link.Original, "fetch track", err)
 		return
 	}
 
-	release, err := app.bp.GetRelease(track.Release.ID)
+	release, err := app.bp.GetReleaseContext(app.ctx, track.Release.ID)
 	if err != nil {
 		app.errorLogWrapper(link.Original, "fetch track release", err)
 		return
@@ -481,14 +725,14 @@ func (app *application) handleTrackLink(link *beatport.Link) {
 			}
 		}
 
-		if err := app.handleTrack(track, downloadsDir, cover); err != nil {
+		if _, err := app.handleTrack(track, downloadsDir, cover); err != nil {
 			app.errorLogWrapper(link.Original, "handle track", err)
 			os.Remove(cover)
 			return
 		}
 
 		if err := app.handleCoverFile(cover); err != nil {
-			app.errorLogWrapper(link.Original, "handle cover file", err)
+			app.postProcessLogWrapper(link.Original, "handle cover file", err)
 			return
 		}
 	})
@@ -498,7 +742,7 @@ func (app *application) handleTrackLink(link *beatport.Link) {
 }
 
 func (app *application) handleReleaseLink(link *beatport.Link) {
-	release, err := app.bp.GetRelease(link.ID)
+	release, err := app.bp.GetReleaseContext(app.ctx, link.ID)
 	if err != nil {
 		app.errorLogWrapper(link.Original, "fetch release", err)
 		return
@@ -520,8 +764,9 @@ func (app *application) handleReleaseLink(link *beatport.Link) {
 		app.semRelease(app.downloadSem)
 	}
 
+	tracks := &collectionTracks{}
 	wg := sync.WaitGroup{}
-	for _, trackUrl := range release.TrackUrls {
+	for i, trackUrl := range release.TrackUrls {
 		app.downloadWorker(&wg, func() {
 			trackLink, err := app.bp.ParseUrl(trackUrl)
 			if err != nil {
@@ -529,7 +774,7 @@ func (app *application) handleReleaseLink(link *beatport.Link) {
 				return
 			}
 
-			track, err := app.bp.GetTrack(trackLink.ID)
+			track, err := app.bp.GetTrackContext(app.ctx, trackLink.ID)
 			if err != nil {
 				app.errorLogWrapper(trackUrl, "fetch release track", err)
 				return
@@ -537,24 +782,89 @@ func (app *application) handleReleaseLink(link *beatport.Link) {
 			trackStoreUrl := track.StoreUrl()
 			track.Release = *release
 
-			if err := app.handleTrack(track, downloadsDir, cover); err != nil {
+			location, err := app.handleTrack(track, downloadsDir, cover)
+			if err != nil {
 				app.errorLogWrapper(trackStoreUrl, "handle track", err)
 				return
 			}
+			tracks.add(i+1, *track, location)
 		})
 	}
 	wg.Wait()
 
+	if app.config.WriteM3U8 {
+		if err := app.writePlaylistFile(downloadsDir, release.Name.String(), playlistContextRelease, tracks); err != nil {
+			app.postProcessLogWrapper(link.Original, "write m3u8 playlist", err)
+		}
+	}
+
 	if err := app.handleCoverFile(cover); err != nil {
-		app.errorLogWrapper(link.Original, "handle cover file", err)
+		app.postProcessLogWrapper(link.Original, "handle cover file", err)
 		return
 	}
 
 	app.cleanup(downloadsDir)
 }
 
+// handleCollectionTrack downloads a playlist or chart track into downloadsDir
+// (or its release directory inside it) and returns the location of the file,
+// or an empty string if the track could not be saved.
+func (app *application) handleCollectionTrack(track *beatport.Track, downloadsDir string) string {
+	trackStoreUrl := track.StoreUrl()
+
+	release, err := app.bp.GetReleaseContext(app.ctx, track.Release.ID)
+	if err != nil {
+		app.errorLogWrapper(trackStoreUrl, "fetch track release", err)
+		return ""
+	}
+	track.Release = *release
+
+	trackDownloadsDir := downloadsDir
+	trackFull, err := app.bp.GetTrackContext(app.ctx, track.ID)
+	if err != nil {
+		app.errorLogWrapper(trackStoreUrl, "fetch full track", err)
+		return ""
+	}
+	track.Number = trackFull.Number
+	if app.config.SortByContext && app.config.ForceReleaseDirectories {
+		trackDownloadsDir, err = app.setupDownloadsDirectory(downloadsDir, release)
+		if err != nil {
+			app.errorLogWrapper(trackStoreUrl, "setup track release directory", err)
+			return ""
+		}
+	}
+
+	var cover string
+	if app.requireCover(true, app.config.ForceReleaseDirectories) {
+		cover, err = app.downloadCover(track.Release.Image, trackDownloadsDir)
+		if err != nil {
+			app.errorLogWrapper(trackStoreUrl, "download track release cover", err)
+		} else if !app.config.ForceReleaseDirectories {
+			defer os.Remove(cover)
+		}
+	}
+
+	location, err := app.handleTrack(track, trackDownloadsDir, cover)
+	if err != nil {
+		app.errorLogWrapper(trackStoreUrl, "handle track", err)
+		os.Remove(cover)
+		app.cleanup(trackDownloadsDir)
+		return ""
+	}
+
+	if app.config.ForceReleaseDirectories {
+		if err := app.handleCoverFile(cover); err != nil {
+			app.postProcessLogWrapper(trackStoreUrl, "handle track release cover file", err)
+			return location
+		}
+	}
+
+	app.cleanup(trackDownloadsDir)
+	return location
+}
+
 func (app *application) handlePlaylistLink(link *beatport.Link) {
-	playlist, err := app.bp.GetPlaylist(link.ID)
+	playlist, err := app.bp.GetPlaylistContext(app.ctx, link.ID)
 	if err != nil {
 		app.errorLogWrapper(link.Original, "fetch playlist", err)
 		return
@@ -566,58 +876,27 @@ func (app *application) handlePlaylistLink(link *beatport.Link) {
 		return
 	}
 
+	var playlistSync *playlistSync
+	if app.syncMode {
+		playlistSync, err = app.startPlaylistSync(link, downloadsDir)
+		if err != nil {
+			app.errorLogWrapper(link.Original, "start playlist sync", err)
+			return
+		}
+	}
+
+	tracks := &collectionTracks{}
 	wg := sync.WaitGroup{}
-	err = ForPaginated[beatport.PlaylistItem](link.ID, "", app.bp.GetPlaylistItems, func(item beatport.PlaylistItem, i int) error {
+	err = ForPaginated[beatport.PlaylistItem](app.ctx, link.ID, "", app.bp.GetPlaylistItemsContext, func(item beatport.PlaylistItem, i int) error {
+		if playlistSync.keep(item.Position, item.Track, tracks) {
+			app.emit(event{Event: eventTrackSkipped, URL: item.Track.StoreUrl(), TrackID: item.Track.ID, Reason: skipReasonSynced})
+			app.report.addSkipped(skipReasonSynced)
+			return nil
+		}
 		app.downloadWorker(&wg, func() {
-			trackStoreUrl := item.Track.StoreUrl()
-
-			release, err := app.bp.GetRelease(item.Track.Release.ID)
-			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch track release", err)
-				return
-			}
-			item.Track.Release = *release
-
-			trackDownloadsDir := downloadsDir
-			trackFull, err := app.bp.GetTrack(item.Track.ID)
-			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch full track", err)
-				return
-			}
-			item.Track.Number = trackFull.Number
-			if app.config.SortByContext && app.config.ForceReleaseDirectories {
-				trackDownloadsDir, err = app.setupDownloadsDirectory(downloadsDir, release)
-				if err != nil {
-					app.errorLogWrapper(trackStoreUrl, "setup track release directory", err)
-					return
-				}
-			}
-
-			var cover string
-			if app.requireCover(true, app.config.ForceReleaseDirectories) {
-				cover, err = app.downloadCover(item.Track.Release.Image, trackDownloadsDir)
-				if err != nil {
-					app.errorLogWrapper(trackStoreUrl, "download track release cover", err)
-				} else if !app.config.ForceReleaseDirectories {
-					defer os.Remove(cover)
-				}
-			}
-
-			if err := app.handleTrack(&item.Track, trackDownloadsDir, cover); err != nil {
-				app.errorLogWrapper(trackStoreUrl, "handle track", err)
-				os.Remove(cover)
-				app.cleanup(trackDownloadsDir)
-				return
+			if location := app.handleCollectionTrack(&item.Track, downloadsDir); location != "" {
+				tracks.add(item.Position, item.Track, location)
 			}
-
-			if app.config.ForceReleaseDirectories {
-				if err := app.handleCoverFile(cover); err != nil {
-					app.errorLogWrapper(trackStoreUrl, "handle track release cover file", err)
-					return
-				}
-			}
-
-			app.cleanup(trackDownloadsDir)
 		})
 		return nil
 	})
@@ -628,10 +907,22 @@ func (app *application) handlePlaylistLink(link *beatport.Link) {
 	}
 
 	wg.Wait()
+
+	if err := app.finishPlaylistSync(playlistSync, tracks); err != nil {
+		app.postProcessLogWrapper(link.Original, "finish playlist sync", err)
+	}
+
+	if app.config.WriteM3U8 {
+		if err := app.writePlaylistFile(downloadsDir, playlist.Name, playlistContextPlaylist, tracks); err != nil {
+			app.postProcessLogWrapper(link.Original, "write m3u8 playlist", err)
+		}
+	}
+
+	app.recordPlaylist(playlist.Name, tracks)
 }
 
 func (app *application) handleChartLink(link *beatport.Link) {
-	chart, err := app.bp.GetChart(link.ID)
+	chart, err := app.bp.GetChartContext(app.ctx, link.ID)
 	if err != nil {
 		app.errorLogWrapper(link.Original, "fetch chart", err)
 		return
@@ -642,6 +933,16 @@ func (app *application) handleChartLink(link *beatport.Link) {
 		app.errorLogWrapper(link.Original, "setup downloads directory", err)
 		return
 	}
+
+	var playlistSync *playlistSync
+	if app.syncMode {
+		playlistSync, err = app.startPlaylistSync(link, downloadsDir)
+		if err != nil {
+			app.errorLogWrapper(link.Original, "start chart sync", err)
+			return
+		}
+	}
+
 	wg := sync.WaitGroup{}
 
 	if app.requireCover(false, true) {
@@ -651,63 +952,26 @@ func (app *application) handleChartLink(link *beatport.Link) {
 				app.errorLogWrapper(link.Original, "download chart cover", err)
 			}
 			if err := app.handleCoverFile(cover); err != nil {
-				app.errorLogWrapper(link.Original, "handle cover file", err)
+				app.postProcessLogWrapper(link.Original, "handle cover file", err)
 				return
 			}
 		})
 	}
 
-	err = ForPaginated[beatport.Track](link.ID, "", app.bp.GetChartTracks, func(track beatport.Track, i int) error {
+	tracks := &collectionTracks{}
+	position := 0
+	err = ForPaginated[beatport.Track](app.ctx, link.ID, "", app.bp.GetChartTracksContext, func(track beatport.Track, i int) error {
+		position++
+		trackPosition := position
+		if playlistSync.keep(trackPosition, track, tracks) {
+			app.emit(event{Event: eventTrackSkipped, URL: track.StoreUrl(), TrackID: track.ID, Reason: skipReasonSynced})
+			app.report.addSkipped(skipReasonSynced)
+			return nil
+		}
 		app.downloadWorker(&wg, func() {
-			trackStoreUrl := track.StoreUrl()
-
-			release, err := app.bp.GetRelease(track.Release.ID)
-			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch track release", err)
-				return
+			if location := app.handleCollectionTrack(&track, downloadsDir); location != "" {
+				tracks.add(trackPosition, track, location)
 			}
-			track.Release = *release
-
-			trackDownloadsDir := downloadsDir
-			trackFull, err := app.bp.GetTrack(track.ID)
-			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch full track", err)
-				return
-			}
-			track.Number = trackFull.Number
-			if app.config.SortByContext && app.config.ForceReleaseDirectories {
-				trackDownloadsDir, err = app.setupDownloadsDirectory(downloadsDir, release)
-				if err != nil {
-					app.errorLogWrapper(trackStoreUrl, "setup track release directory", err)
-					return
-				}
-			}
-
-			var cover string
-			if app.requireCover(true, app.config.ForceReleaseDirectories) {
-				cover, err = app.downloadCover(track.Release.Image, trackDownloadsDir)
-				if err != nil {
-					app.errorLogWrapper(trackStoreUrl, "download track release cover", err)
-				} else if !app.config.ForceReleaseDirectories {
-					defer os.Remove(cover)
-				}
-			}
-
-			if err := app.handleTrack(&track, trackDownloadsDir, cover); err != nil {
-				app.errorLogWrapper(trackStoreUrl, "handle track", err)
-				os.Remove(cover)
-				app.cleanup(trackDownloadsDir)
-				return
-			}
-
-			if app.config.ForceReleaseDirectories {
-				if err := app.handleCoverFile(cover); err != nil {
-					app.errorLogWrapper(trackStoreUrl, "handle track release cover file", err)
-					return
-				}
-			}
-
-			app.cleanup(trackDownloadsDir)
 		})
 		return nil
 	})
@@ -718,10 +982,22 @@ func (app *application) handleChartLink(link *beatport.Link) {
 	}
 
 	wg.Wait()
+
+	if err := app.finishPlaylistSync(playlistSync, tracks); err != nil {
+		app.postProcessLogWrapper(link.Original, "finish chart sync", err)
+	}
+
+	if app.config.WriteM3U8 {
+		if err := app.writePlaylistFile(downloadsDir, chart.Name, playlistContextChart, tracks); err != nil {
+			app.postProcessLogWrapper(link.Original, "write m3u8 playlist", err)
+		}
+	}
+
+	app.recordPlaylist(chart.Name, tracks)
 }
 
 func (app *application) handleLabelLink(link *beatport.Link) {
-	label, err := app.bp.GetLabel(link.ID)
+	label, err := app.bp.GetLabelContext(app.ctx, link.ID)
 	if err != nil {
 		app.errorLogWrapper(link.Original, "fetch label", err)
 		return
@@ -736,18 +1012,18 @@ func (app *application) handleLabelLink(link *beatport.Link) {
 	wg := sync.WaitGroup{}
 	var relGroup singleflight.Group[int64, *beatport.Release]
 
-	err = ForPaginated[beatport.Track](link.ID, link.Params, app.bp.GetLabelTracks, func(t beatport.Track, i int) error {
+	err = ForPaginated[beatport.Track](app.ctx, link.ID, link.Params, app.bp.GetLabelTracksContext, func(t beatport.Track, i int) error {
 		app.downloadWorker(&wg, func() {
 			trackStoreUrl := t.StoreUrl()
 
-			track, err := app.bp.GetTrack(t.ID)
+			track, err := app.bp.GetTrackContext(app.ctx, t.ID)
 			if err != nil {
 				app.errorLogWrapper(trackStoreUrl, "fetch track", err)
 				return
 			}
 
-			release, _, err := relGroup.Do(context.Background(), track.Release.ID, func(ctx context.Context) (*beatport.Release, error) {
-				release, err := app.bp.GetRelease(track.Release.ID)
+			release, _, err := relGroup.Do(app.ctx, track.Release.ID, func(ctx context.Context) (*beatport.Release, error) {
+				release, err := app.bp.GetReleaseContext(ctx, track.Release.ID)
 				if err != nil {
 					return nil, err
 				}
@@ -773,14 +1049,14 @@ func (app *application) handleLabelLink(link *beatport.Link) {
 				app.errorLogWrapper(trackStoreUrl, "download track release cover", err)
 			}
 
-			if err := app.handleTrack(track, releaseDir, cover); err != nil {
+			if _, err := app.handleTrack(track, releaseDir, cover); err != nil {
 				app.errorLogWrapper(trackStoreUrl, "handle track", err)
 				app.cleanup(releaseDir)
 				return
 			}
 
 			if err := app.handleCoverFile(cover); err != nil {
-				app.errorLogWrapper(trackStoreUrl, "handle cover file", err)
+				app.postProcessLogWrapper(trackStoreUrl, "handle cover file", err)
 				return
 			}
 
@@ -798,7 +1074,7 @@ func (app *application) handleLabelLink(link *beatport.Link) {
 }
 
 func (app *application) handleArtistLink(link *beatport.Link) {
-	artist, err := app.bp.GetArtist(link.ID)
+	artist, err := app.bp.GetArtistContext(app.ctx, link.ID)
 	if err != nil {
 		app.errorLogWrapper(link.Original, "fetch artist", err)
 		return
@@ -813,17 +1089,17 @@ func (app *application) handleArtistLink(link *beatport.Link) {
 	wg := sync.WaitGroup{}
 	var relGroup singleflight.Group[int64, *beatport.Release]
 
-	err = ForPaginated[beatport.Track](link.ID, link.Params, app.bp.GetArtistTracks, func(t beatport.Track, i int) error {
+	err = ForPaginated[beatport.Track](app.ctx, link.ID, link.Params, app.bp.GetArtistTracksContext, func(t beatport.Track, i int) error {
 		app.downloadWorker(&wg, func() {
 			trackStoreUrl := t.StoreUrl()
-			track, err := app.bp.GetTrack(t.ID)
+			track, err := app.bp.GetTrackContext(app.ctx, t.ID)
 			if err != nil {
 				app.errorLogWrapper(trackStoreUrl, "fetch full track", err)
 				return
 			}
 
-			release, _, err := relGroup.Do(context.Background(), track.Release.ID, func(ctx context.Context) (*beatport.Release, error) {
-				release, err := app.bp.GetRelease(track.Release.ID)
+			release, _, err := relGroup.Do(app.ctx, track.Release.ID, func(ctx context.Context) (*beatport.Release, error) {
+				release, err := app.bp.GetReleaseContext(ctx, track.Release.ID)
 				if err != nil {
 					return nil, err
 				}
@@ -851,7 +1127,7 @@ func (app *application) handleArtistLink(link *beatport.Link) {
 				}
 			}
 
-			if err := app.handleTrack(track, releaseDir, cover); err != nil {
+			if _, err := app.handleTrack(track, releaseDir, cover); err != nil {
 				app.errorLogWrapper(trackStoreUrl, "handle track", err)
 				os.Remove(cover)
 				app.cleanup(releaseDir)
@@ -859,7 +1135,7 @@ func (app *application) handleArtistLink(link *beatport.Link) {
 			}
 
 			if err := app.handleCoverFile(cover); err != nil {
-				app.errorLogWrapper(trackStoreUrl, "handle cover file", err)
+				app.postProcessLogWrapper(trackStoreUrl, "handle cover file", err)
 				return
 			}
 
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/history"
+)
+
+func TestHandleTrackHistory(t *testing.T) {
+	dir := t.TempDir()
+	location := filepath.Join(dir, "01. deadmau5 - Strobe (Original Mix).flac")
+	if err := os.WriteFile(location, []byte("flac"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := history.Open(filepath.Join(dir, "beatportdl-history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	entry, err := history.NewEntry(1, "lossless", "", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		trackExists string
+		skipped     int
+		updated     int
+	}{
+		{"skip", 1, 0},
+		{"update", 0, 1},
+	} {
+		app := &application{
+			config:  &config.AppConfig{Quality: "lossless", TrackExists: tt.trackExists},
+			logger:  slog.New(newConsoleHandler(func() io.Writer { return io.Discard }, slog.LevelInfo)),
+			history: store,
+			report:  newRunReport(),
+		}
+		got, err := app.handleTrack(&beatport.Track{ID: 1}, dir, "")
+		if err != nil {
+			t.Fatalf("handleTrack() with %s failed: %v", tt.trackExists, err)
+		}
+		if got != location {
+			t.Errorf("handleTrack() with %s = %q, want %q", tt.trackExists, got, location)
+		}
+		if app.report.skippedHistory != tt.skipped || app.report.updated != tt.updated {
+			t.Errorf("track_exists %s: skipped %d, updated %d, want %d, %d",
+				tt.trackExists, app.report.skippedHistory, app.report.updated, tt.skipped, tt.updated)
+		}
+	}
+}
+
+func TestHistoryFormat(t *testing.T) {
+	dir := t.TempDir()
+	location := filepath.Join(dir, "01. deadmau5 - Strobe (Original Mix).flac")
+	if err := os.WriteFile(location, []byte("flac"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := history.Open(filepath.Join(dir, "beatportdl-history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	original := &application{config: &config.AppConfig{Quality: "lossless", Format: "original"}, history: store}
+	entry, err := history.NewEntry(1, "lossless", original.historyFormat(), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := store.Lookup(1, "lossless", original.historyFormat()); !found {
+		t.Errorf("original download not found in history")
+	}
+	transcoded := &application{config: &config.AppConfig{Quality: "lossless", Format: "aiff"}, history: store}
+	if _, found := store.Lookup(1, "lossless", transcoded.historyFormat()); found {
+		t.Errorf("original download found in history after switching the format to aiff")
+	}
+}
+
+func TestSaveFilenamePreview(t *testing.T) {
+	app := &application{config: &config.AppConfig{TrackFileTemplate: "{artists} - {name}"}}
+	track := &beatport.Track{
+		Name:    "Strobe",
+		MixName: "Original Mix",
+		Artists: beatport.Artists{{Name: "deadmau5"}},
+	}
+
+	full := app.saveFilename(track, "high")
+	preview := app.saveFilename(track, "preview")
+	if full != app.trackFilename(track) {
+		t.Errorf("saveFilename() for high = %q, want %q", full, app.trackFilename(track))
+	}
+	if preview != full+previewFileSuffix {
+		t.Errorf("saveFilename() for preview = %q, want %q", preview, full+previewFileSuffix)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// With "output: json" the downloads are reported as newline-delimited JSON
+// events on stdout instead of log lines and progress bars, so that scripts
+// can tell which tracks were saved. Log messages go to stderr.
+
+const (
+	eventUrlStarted       = "url_started"
+	eventTrackResolved    = "track_resolved"
+	eventDownloadProgress = "download_progress"
+	eventTrackSaved       = "track_saved"
+	eventTrackSkipped     = "track_skipped"
+	eventRetry            = "retry"
+	eventError            = "error"
+)
+
+// Reasons of track_skipped events.
+const (
+	skipReasonHistory    = "history"
+	skipReasonFileExists = "file_exists"
+	skipReasonSynced     = "synced"
+)
+
+// progressEventInterval is the minimum time between two download_progress
+// events of a track.
+const progressEventInterval = time.Second
+
+type event struct {
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	URL     string    `json:"url,omitempty"`
+	TrackID int64     `json:"track_id,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Artists string    `json:"artists,omitempty"`
+	Release string    `json:"release,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	Quality string    `json:"quality,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	Current int64     `json:"current,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+	Unit    string    `json:"unit,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Step    string    `json:"step,omitempty"`
+	Attempt int       `json:"attempt,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type eventWriter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+func newEventWriter(w io.Writer) *eventWriter {
+	return &eventWriter{encoder: json.NewEncoder(w)}
+}
+
+func (w *eventWriter) emit(e event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.encoder.Encode(e)
+}
+
+// emit writes an event in JSON output mode and does nothing otherwise.
+func (app *application) emit(e event) {
+	if app.events != nil {
+		app.events.emit(e)
+	}
+}
+
+// progressBar is implemented by *mpb.Bar and eventProgressBar.
+type progressBar interface {
+	SetCurrent(current int64)
+	EwmaIncrInt64(n int64, iterDur time.Duration)
+	ProxyReader(r io.Reader) io.ReadCloser
+	Abort(drop bool)
+}
+
+// downloadProgress describes the track a progress bar is shown for.
+type downloadProgress struct {
+	name string
+	url  string
+}
+
+// addProgressBar adds a progress bar for a download of total units, which
+// reports download_progress events in JSON output mode.
+func (app *application) addProgressBar(progress *downloadProgress, total int64, unit string) progressBar {
+	if app.events != nil {
+		return &eventProgressBar{events: app.events, url: progress.url, unit: unit, total: total}
+	}
+	return app.pbp.AddBar(total, ProgressBarOptions(progress.name)...)
+}
+
+type eventProgressBar struct {
+	events *eventWriter
+	url    string
+	unit   string
+	total  int64
+
+	mutex    sync.Mutex
+	current  int64
+	reported time.Time
+}
+
+func (b *eventProgressBar) SetCurrent(current int64) {
+	b.mutex.Lock()
+	b.current = current
+	b.mutex.Unlock()
+}
+
+func (b *eventProgressBar) EwmaIncrInt64(n int64, _ time.Duration) {
+	b.add(n)
+}
+
+func (b *eventProgressBar) ProxyReader(r io.Reader) io.ReadCloser {
+	return &eventProgressReader{Reader: r, bar: b}
+}
+
+func (b *eventProgressBar) Abort(bool) {}
+
+// add reports the progress at most once per progressEventInterval and when
+// the download is complete.
+func (b *eventProgressBar) add(n int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.current += n
+	now := time.Now()
+	if now.Sub(b.reported) < progressEventInterval && b.current != b.total {
+		return
+	}
+	b.reported = now
+
+	e := event{Event: eventDownloadProgress, URL: b.url, Current: b.current, Unit: b.unit}
+	if b.total > 0 {
+		e.Total = b.total
+	}
+	b.events.emit(e)
+}
+
+type eventProgressReader struct {
+	io.Reader
+	bar *eventProgressBar
+}
+
+func (r *eventProgressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.bar.add(int64(n))
+	}
+	return n, err
+}
+
+func (r *eventProgressReader) Close() error {
+	if closer, ok := r.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readEvents(t *testing.T, data []byte) []event {
+	t.Helper()
+	var events []event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var e event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestDownloadProgressEvents(t *testing.T) {
+	content := bytes.Repeat([]byte("beatportdl"), 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "track.flac", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	destination := filepath.Join(t.TempDir(), "track.flac")
+	if err := os.WriteFile(destination+partFileSuffix, content[:4000], 0644); err != nil {
+		t.Fatalf("write part file: %v", err)
+	}
+
+	var out bytes.Buffer
+	app := &application{events: newEventWriter(&out)}
+	progress := &downloadProgress{name: "Strobe (Original Mix) [FLAC]", url: "https://www.beatport.com/track/strobe/1"}
+	if err := app.downloadFile(context.Background(), server.URL, destination, progress); err != nil {
+		t.Fatalf("downloadFile() failed: %v", err)
+	}
+
+	events := readEvents(t, out.Bytes())
+	if len(events) == 0 {
+		t.Fatalf("no events written")
+	}
+	last := events[len(events)-1]
+	if last.Event != eventDownloadProgress || last.URL != progress.url || last.Unit != "bytes" {
+		t.Errorf("last event = %+v", last)
+	}
+	if last.Current != int64(len(content)) || last.Total != int64(len(content)) {
+		t.Errorf("progress = %d/%d, want %d/%d", last.Current, last.Total, len(content), len(content))
+	}
+}
+
+func TestErrorEvents(t *testing.T) {
+	var out, console bytes.Buffer
+	app := &application{
+		events: newEventWriter(&out),
+		logger: slog.New(newConsoleHandler(func() io.Writer { return &console }, slog.LevelInfo)),
+	}
+	app.errorLogWrapper("https://www.beatport.com/release/x/1", "fetch release", errors.New("not found"))
+	app.errorLogWrapper("https://www.beatport.com/release/x/2", "fetch release", context.Canceled)
+	app.LogError("handle URL", ErrUnsupportedLinkType)
+
+	events := readEvents(t, out.Bytes())
+	expected := []event{
+		{Event: eventError, URL: "https://www.beatport.com/release/x/1", Step: "fetch release", Error: "not found"},
+		{Event: eventError, Step: "handle URL", Error: ErrUnsupportedLinkType.Error()},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("events = %+v, want %+v", events, expected)
+	}
+	for i, e := range events {
+		if e.Time.IsZero() {
+			t.Errorf("event %d has no time", i)
+		}
+		e.Time = time.Time{}
+		if e != expected[i] {
+			t.Errorf("event %d = %+v, want %+v", i, e, expected[i])
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/collection"
+)
+
+// absLocation returns the absolute path of location, DJ software can't
+// resolve paths relative to the working directory.
+func absLocation(location string) string {
+	if abs, err := filepath.Abs(location); err == nil {
+		return abs
+	}
+	return location
+}
+
+// recordTrack adds a saved track to the export session.
+func (app *application) recordTrack(track *beatport.Track, location string) {
+	if app.session == nil {
+		return
+	}
+	releaseDate := track.Release.Date
+	if releaseDate == "" {
+		releaseDate = track.PublishDate
+	}
+	key := track.Key
+	location = absLocation(location)
+	var size int64
+	if info, err := os.Stat(location); err == nil {
+		size = info.Size()
+	}
+	app.session.AddTrack(collection.Track{
+		Location:    location,
+		Title:       track.Name.String(),
+		MixName:     track.MixName.String(),
+		Artists:     track.Artists.Display(0, ""),
+		Remixers:    track.Remixers.Display(0, ""),
+		Album:       track.Release.Name.String(),
+		Number:      track.Number,
+		Genre:       track.Genre.Name,
+		Label:       track.Release.Label.Name,
+		Key:         track.Key.Display(app.config.KeySystem),
+		MusicalKey:  &key,
+		BPM:         track.BPM,
+		ReleaseDate: releaseDate,
+		ISRC:        track.ISRC,
+		Length:      time.Duration(track.LengthMs) * time.Millisecond,
+		Size:        size,
+	})
+}
+
+// recordPlaylist adds a downloaded playlist or chart to the export session.
+func (app *application) recordPlaylist(name string, tracks *collectionTracks) {
+	if app.session == nil {
+		return
+	}
+	items := tracks.sorted()
+	playlist := collection.Playlist{Name: name}
+	for _, item := range items {
+		playlist.Locations = append(playlist.Locations, absLocation(item.Location))
+	}
+	app.session.AddPlaylist(playlist)
+}
+
+// exportCollections writes the tracks and playlists of the finished run to the
+// configured DJ software collections and starts a new session.
+func (app *application) exportCollections() {
+	if app.session.Empty() {
+		return
+	}
+	if app.config.RekordboxXML != "" {
+		if err := collection.WriteRekordbox(app.config.RekordboxXML, app.session); err != nil {
+			app.postProcessLogWrapper(app.config.RekordboxXML, "export rekordbox collection", err)
+		}
+	}
+	if app.config.TraktorNML != "" {
+		if err := collection.WriteTraktor(app.config.TraktorNML, app.session); err != nil {
+			app.postProcessLogWrapper(app.config.TraktorNML, "export traktor collection", err)
+		}
+	}
+	app.session = collection.NewSession()
+}
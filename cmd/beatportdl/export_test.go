@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/collection"
+)
+
+func TestRecordRelativeLocation(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relative := filepath.Join("downloads", "Strobe", "01. deadmau5 - Strobe (Original Mix).flac")
+	expected := filepath.Join(wd, relative)
+
+	app := &application{
+		config:  &config.AppConfig{KeySystem: "standard"},
+		session: collection.NewSession(),
+	}
+	track := beatport.Track{ID: 1}
+	app.recordTrack(&track, relative)
+	tracks := &collectionTracks{}
+	tracks.add(1, track, relative)
+	app.recordPlaylist("Strobe", tracks)
+
+	if got := app.session.Tracks(); len(got) != 1 || got[0].Location != expected {
+		t.Errorf("track locations = %+v, want %q", got, expected)
+	}
+	if got := app.session.Playlists(); len(got) != 1 || len(got[0].Locations) != 1 || got[0].Locations[0] != expected {
+		t.Errorf("playlist locations = %+v, want %q", got, expected)
+	}
+}
@@ -14,15 +14,35 @@ import (
 
 var (
 	ErrUnsupportedLinkType = errors.New("unsupported link type")
+	ErrConfigNotFound      = errors.New("config file not found")
 )
 
-func Setup() (cfg *config.AppConfig, cachePath string, err error) {
-	configFilePath, exists, err := FindConfigFile()
-	if err != nil {
-		return nil, "", err
+// Setup loads the config file, creating it interactively if it doesn't exist,
+// with the BEATPORTDL_* environment variables and the flags applied on top.
+// Without a config file the credentials may come from the environment or the
+// flags as well, so that nothing is asked. The selection can point to another
+// config file and pick one of its profiles, which gets its own credentials
+// cache. In JSON output mode nothing is asked either, the prompts would mix
+// with the events on stdout.
+func Setup(flags config.Overrides, selection configSelection) (cfg *config.AppConfig, cachePath string, err error) {
+	configFilePath, exists := selection.Path, fileExists(selection.Path)
+	if configFilePath == "" {
+		configFilePath, exists, err = FindConfigFile()
+		if err != nil {
+			return nil, "", err
+		}
 	}
 
-	if !exists {
+	env := config.EnvOverrides(os.LookupEnv)
+	credentials := func(key string) bool {
+		return flags[key] != "" || env[key] != ""
+	}
+	parsePath := configFilePath
+	if !exists && credentials("username") && credentials("password") {
+		parsePath = ""
+	} else if !exists && (flags["output"] == "json" || env["output"] == "json") {
+		return nil, configFilePath, fmt.Errorf("%w: %s", ErrConfigNotFound, configFilePath)
+	} else if !exists {
 		fmt.Println("Config file not found, creating a new one:", configFilePath)
 
 		fmt.Print("Username: ")
@@ -38,7 +58,7 @@ func Setup() (cfg *config.AppConfig, cachePath string, err error) {
 			DownloadsDirectory: downloadsDir,
 		}
 
-		fmt.Println("1. Lossless (44.1 khz FLAC)\n2. High (256 kbps AAC)\n3. Medium (128 kbps AAC)\n4. Medium HLS (128 kbps AAC)")
+		fmt.Println("1. Lossless (44.1 khz FLAC)\n2. High (256 kbps AAC)\n3. Medium (128 kbps AAC)\n4. Medium HLS (128 kbps AAC)\n5. Preview (128 kbps AAC sample clip)")
 		for {
 			fmt.Print("Quality: ")
 			qualityNumber := GetLine()
@@ -51,6 +71,8 @@ func Setup() (cfg *config.AppConfig, cachePath string, err error) {
 				cfg.Quality = "medium"
 			case "4":
 				cfg.Quality = "medium-hls"
+			case "5":
+				cfg.Quality = "preview"
 			default:
 				fmt.Println("Invalid quality")
 				continue
@@ -63,12 +85,12 @@ func Setup() (cfg *config.AppConfig, cachePath string, err error) {
 		}
 	}
 
-	parsedConfig, err := config.Parse(configFilePath)
+	parsedConfig, err := config.Parse(parsePath, selection.Profile, env, flags)
 	if err != nil {
 		return nil, configFilePath, fmt.Errorf("load config: %w", err)
 	}
 
-	cacheFilePath, exists, err := FindCacheFile()
+	cacheFilePath, _, err := FindCacheFile(selection.Profile)
 	if err != nil {
 		return nil, configFilePath, fmt.Errorf("get executable path: %w", err)
 	}
@@ -104,7 +126,7 @@ func (app *application) filtersPrompt(rawURL string) {
 	switch link.Type {
 	case beatport.LabelLink:
 		listItemName = "releases"
-		labelReleases, err := app.bp.GetLabelReleases(link.ID, 1, params)
+		labelReleases, err := app.bp.GetLabelReleasesContext(app.ctx, link.ID, 1, params)
 		if err != nil {
 			fmt.Println("Could not fetch label releases:", err)
 			return
@@ -113,7 +135,7 @@ func (app *application) filtersPrompt(rawURL string) {
 		stats = newEntityStats(labelReleases.Count, &labelReleases.Facets)
 	case beatport.ArtistLink:
 		listItemName = "tracks"
-		artistTracks, err := app.bp.GetArtistTracks(link.ID, 1, params)
+		artistTracks, err := app.bp.GetArtistTracksContext(app.ctx, link.ID, 1, params)
 		if err != nil {
 			fmt.Println("Could not fetch artist tracks:", err)
 			return
@@ -364,7 +386,7 @@ func normaliseDateBound(input string, endOfPeriod bool) string {
 }
 
 func (app *application) search(input string) {
-	results, err := app.bp.Search(input)
+	results, err := app.bp.SearchContext(app.ctx, input)
 	if err != nil {
 		app.FatalError("beatport", err)
 	}
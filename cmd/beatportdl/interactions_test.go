@@ -0,0 +1,16 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"unspok3n/beatportdl/config"
+)
+
+func TestSetupJSONWithoutConfig(t *testing.T) {
+	selection := configSelection{Path: filepath.Join(t.TempDir(), "beatportdl-config.yml")}
+	_, _, err := Setup(config.Overrides{"output": "json"}, selection)
+	if !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("Setup() error = %v, want %v", err, ErrConfigNotFound)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+var (
+	ErrNoTrackID = errors.New("no track id in the tags or the file name")
+)
+
+var templatePlaceholderRegexp = regexp.MustCompile(`\{(\w+)[^}]*}`)
+
+// cachedRelease is a release shared by the tracks of an existing library,
+// with its cover downloaded on first use.
+type cachedRelease struct {
+	release *beatport.Release
+	cover   func() (string, error)
+}
+
+// releaseCache fetches every release of an existing library only once.
+type releaseCache struct {
+	mu       sync.Mutex
+	releases map[int64]func() (*cachedRelease, error)
+	covers   []string
+}
+
+func newReleaseCache() *releaseCache {
+	return &releaseCache{releases: make(map[int64]func() (*cachedRelease, error))}
+}
+
+// removeCovers removes the covers downloaded for the cached releases.
+func (r *releaseCache) removeCovers() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cover := range r.covers {
+		os.Remove(cover)
+	}
+	r.covers = nil
+}
+
+func (r *releaseCache) get(app *application, id int64) (*cachedRelease, error) {
+	r.mu.Lock()
+	get, ok := r.releases[id]
+	if !ok {
+		get = sync.OnceValues(func() (*cachedRelease, error) {
+			release, err := app.bp.GetReleaseContext(app.ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			cover := sync.OnceValues(func() (string, error) {
+				path, err := app.downloadCover(release.Image, os.TempDir())
+				if err != nil {
+					return "", err
+				}
+				r.mu.Lock()
+				r.covers = append(r.covers, path)
+				r.mu.Unlock()
+				return path, nil
+			})
+			return &cachedRelease{release: release, cover: cover}, nil
+		})
+		r.releases[id] = get
+	}
+	r.mu.Unlock()
+	return get()
+}
+
+// fileTrackID returns the track ID from the track_id tag, falling back to the
+// file name.
+func fileTrackID(path string, tag string, idPattern *regexp.Regexp) (int64, error) {
+	if id, err := strconv.ParseInt(strings.TrimSpace(tag), 10, 64); err == nil {
+		return id, nil
+	}
+	if idPattern != nil {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if m := idPattern.FindStringSubmatch(name); m != nil && m[1] != "" {
+			return strconv.ParseInt(m[1], 10, 64)
+		}
+	}
+	return 0, ErrNoTrackID
+}
+
+// trackIDPattern turns a track file template into a regexp matching the
+// file names it produces, capturing the {id} placeholder. It returns nil if
+// the template has no {id}.
+func trackIDPattern(template string, whitespace string) *regexp.Regexp {
+	// file names are matched against the last component of nested templates
+	template = template[strings.LastIndex(template, "/")+1:]
+	matches := templatePlaceholderRegexp.FindAllStringSubmatchIndex(template, -1)
+	if !slices.ContainsFunc(matches, func(loc []int) bool { return template[loc[2]:loc[3]] == "id" }) {
+		return nil
+	}
+
+	// optional sections become optional groups
+	literal := func(s string) string {
+		if whitespace != "" {
+			s = strings.ReplaceAll(s, " ", whitespace)
+		}
+		var b strings.Builder
+		start := 0
+		for i := 0; i < len(s); i++ {
+			if s[i] != '<' && s[i] != '>' {
+				continue
+			}
+			b.WriteString(regexp.QuoteMeta(s[start:i]))
+			if s[i] == '<' {
+				b.WriteString("(?:")
+			} else {
+				b.WriteString(")?")
+			}
+			start = i + 1
+		}
+		b.WriteString(regexp.QuoteMeta(s[start:]))
+		return b.String()
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	id := false
+	for _, loc := range matches {
+		b.WriteString(literal(template[last:loc[0]]))
+		if template[loc[2]:loc[3]] == "id" && !id {
+			b.WriteString(`(\d+)`)
+			id = true
+		} else {
+			b.WriteString(`.*?`)
+		}
+		last = loc[1]
+	}
+	b.WriteString(literal(template[last:]))
+	// duplicate file names get a " (n)" suffix
+	b.WriteString(`(?: \(\d+\))?$`)
+
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFileTrackID(t *testing.T) {
+	pattern := trackIDPattern("{number}. {artists} - {name} [{id}]", "")
+
+	tests := []struct {
+		path string
+		tag  string
+		want int64
+	}{
+		{"01. Artist - Track (Extended Mix) [17592034].flac", "", 17592034},
+		{"01. Artist - Track [17592034] (1).mp3", "", 17592034},
+		{"01. Artist - Track [17592034].flac", "123", 123},
+		{"01. 4 Strings - Take Me Away [42].m4a", "", 42},
+	}
+	for _, tt := range tests {
+		got, err := fileTrackID(tt.path, tt.tag, pattern)
+		if err != nil {
+			t.Errorf("fileTrackID(%q) error: %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("fileTrackID(%q) = %d, want %d", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := fileTrackID("01. Artist - Track.flac", "", pattern); err != ErrNoTrackID {
+		t.Errorf("fileTrackID() error = %v, want %v", err, ErrNoTrackID)
+	}
+}
+
+func TestTrackIDPattern(t *testing.T) {
+	if trackIDPattern("{number}. {artists} - {name}", "") != nil {
+		t.Error("expected no pattern for a template without {id}")
+	}
+
+	pattern := trackIDPattern("{id} {name}", "_")
+	if m := pattern.FindStringSubmatch("123_Some_Name"); m == nil || m[1] != "123" {
+		t.Errorf("unexpected match %v", m)
+	}
+
+	pattern = trackIDPattern("{artists} - {name|upper}< ({mix_name|omit:Original Mix})> [{id}]", "")
+	for name, want := range map[string]string{
+		"A - TRACK (Extended Mix) [42]": "42",
+		"A - TRACK [43]":                "43",
+	} {
+		if m := pattern.FindStringSubmatch(name); m == nil || m[1] != want {
+			t.Errorf("match of %q = %v, want %s", name, m, want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"unspok3n/beatportdl/internal/logrotate"
+)
+
+// Log records are printed to the console in the short "[url] message: error"
+// form and written to the error log with their time, level and attributes,
+// as text or JSON depending on log_format.
+
+// setupLogging creates the logger, which also writes to the error log when
+// write_error_log is enabled.
+func (app *application) setupLogging() error {
+	level := slog.LevelInfo
+	if app.config.Verbose {
+		level = slog.LevelDebug
+	}
+
+	handlers := multiHandler{newConsoleHandler(func() io.Writer { return app.logWriter }, level)}
+
+	if app.config.WriteErrorLog {
+		logFilePath, _, err := FindErrorLogFile()
+		if err != nil {
+			return err
+		}
+		file, err := logrotate.Open(logFilePath, int64(app.config.LogMaxSize)*1000*1000, app.config.LogMaxBackups)
+		if err != nil {
+			return err
+		}
+		app.logFile = file
+
+		options := &slog.HandlerOptions{Level: level}
+		if app.config.LogFormat == "json" {
+			handlers = append(handlers, slog.NewJSONHandler(file, options))
+		} else {
+			handlers = append(handlers, slog.NewTextHandler(file, options))
+		}
+	}
+
+	app.logger = slog.New(handlers)
+	return nil
+}
+
+// consoleHandler prints records without time and level. The url and error
+// attributes are part of the message, other attributes follow as key=value.
+type consoleHandler struct {
+	output func() io.Writer
+	level  slog.Leveler
+	attrs  []slog.Attr
+	mutex  *sync.Mutex
+}
+
+func newConsoleHandler(output func() io.Writer, level slog.Leveler) *consoleHandler {
+	return &consoleHandler{output: output, level: level, mutex: &sync.Mutex{}}
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var url, errText string
+	var rest []string
+	visit := func(a slog.Attr) bool {
+		switch a.Key {
+		case "url":
+			url = a.Value.String()
+		case "error":
+			errText = a.Value.String()
+		default:
+			rest = append(rest, a.Key+"="+a.Value.String())
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		visit(a)
+	}
+	r.Attrs(visit)
+
+	var b strings.Builder
+	if url != "" {
+		b.WriteString("[" + url + "] ")
+	}
+	b.WriteString(r.Message)
+	if errText != "" {
+		b.WriteString(": " + errText)
+	}
+	for _, attr := range rest {
+		b.WriteString(" " + attr)
+	}
+	b.WriteString("\n")
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err := io.WriteString(h.output(), b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &clone
+}
+
+// WithGroup is not supported, the attributes of a group are printed as if
+// they weren't in one.
+func (h *consoleHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// multiHandler passes records on to every handler.
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range m {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	var console, file bytes.Buffer
+	logger := slog.New(multiHandler{
+		newConsoleHandler(func() io.Writer { return &console }, slog.LevelInfo),
+		slog.NewJSONHandler(&file, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	})
+
+	url := "https://www.beatport.com/track/strobe/1"
+	logger.Error("handle track", "url", url, "error", errors.New("save track: bad status"))
+	logger.Info("updating tags", "url", url)
+	logger.With("track_id", 1).Info("Finished downloading")
+	logger.Debug("fetch", "endpoint", "/catalog/tracks/1/", "status", 200)
+
+	expected := "[https://www.beatport.com/track/strobe/1] handle track: save track: bad status\n" +
+		"[https://www.beatport.com/track/strobe/1] updating tags\n" +
+		"Finished downloading track_id=1\n"
+	if console.String() != expected {
+		t.Errorf("console output = %q, want %q", console.String(), expected)
+	}
+
+	lines := strings.Split(strings.TrimSpace(file.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("file has %d records, want 4:\n%s", len(lines), file.String())
+	}
+	if !strings.Contains(lines[0], `"level":"ERROR"`) || !strings.Contains(lines[0], `"error":"save track: bad status"`) ||
+		!strings.Contains(lines[0], `"time":`) {
+		t.Errorf("unexpected error record %s", lines[0])
+	}
+	if !strings.Contains(lines[3], `"level":"DEBUG"`) || !strings.Contains(lines[3], `"status":200`) {
+		t.Errorf("unexpected debug record %s", lines[3])
+	}
+}
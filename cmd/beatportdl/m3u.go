@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+const (
+	playlistFileExtension = ".m3u8"
+	playlistContextPrefix = "#BEATPORTDL-CONTEXT:"
+)
+
+// The context line of a playlist tells what it was downloaded from, so that
+// reorganize can tell playlist and chart folders from release folders.
+const (
+	playlistContextRelease  = "release"
+	playlistContextPlaylist = "playlist"
+	playlistContextChart    = "chart"
+)
+
+// writePlaylistFile writes an extended M3U playlist of tracks into dir. Entries
+// are ordered by position and refer to the files relative to dir, so the
+// playlist keeps working when the whole directory is moved.
+func (app *application) writePlaylistFile(dir string, name string, context string, tracks *collectionTracks) error {
+	items := tracks.sorted()
+	if len(items) == 0 {
+		return nil
+	}
+
+	fileName := filepath.Base(dir)
+	if !app.config.SortByContext {
+		fileName = beatport.SanitizePath(beatport.SanitizeForPath(name), app.config.WhitespaceCharacter)
+	}
+	playlistPath := filepath.Join(dir, fileName+playlistFileExtension)
+
+	f, err := os.Create(playlistPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "#EXTM3U")
+	fmt.Fprintln(w, playlistContextPrefix+context)
+	for _, item := range items {
+		relPath, err := filepath.Rel(dir, item.Location)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "#EXTINF:%d,%s\n", int(item.Track.LengthMs)/1000, playlistEntryTitle(&item.Track))
+		fmt.Fprintln(w, filepath.ToSlash(relPath))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readPlaylistContext returns the context of a playlist written by
+// writePlaylistFile, or an empty string if it has none.
+func readPlaylistContext(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if context, found := strings.CutPrefix(line, playlistContextPrefix); found {
+			return context, nil
+		}
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+	}
+	return "", scanner.Err()
+}
+
+func playlistEntryTitle(track *beatport.Track) string {
+	title := track.Name.String()
+	if mixName := track.MixName.String(); mixName != "" {
+		title = fmt.Sprintf("%s (%s)", title, mixName)
+	}
+	artists := track.Artists.Display(0, "")
+	if artists == "" {
+		return title
+	}
+	return fmt.Sprintf("%s - %s", artists, title)
+}
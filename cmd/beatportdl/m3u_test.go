@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestWritePlaylistFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "My Playlist")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	app := &application{config: &config.AppConfig{SortByContext: true}}
+
+	tracks := &collectionTracks{}
+	tracks.add(2, beatport.Track{
+		Name:     "Second",
+		LengthMs: 61500,
+		Artists:  beatport.Artists{{Name: "B"}},
+	}, filepath.Join(dir, "Release", "02. B - Second.flac"))
+	tracks.add(1, beatport.Track{
+		Name:     "First",
+		MixName:  "Extended Mix",
+		LengthMs: 300000,
+		Artists:  beatport.Artists{{Name: "A"}, {Name: "C"}},
+	}, filepath.Join(dir, "01. A - First.flac"))
+
+	if err := app.writePlaylistFile(dir, "My Playlist", playlistContextPlaylist, tracks); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "My Playlist.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "#EXTM3U\n" +
+		"#BEATPORTDL-CONTEXT:playlist\n" +
+		"#EXTINF:300,A, C - First (Extended Mix)\n" +
+		"01. A - First.flac\n" +
+		"#EXTINF:61,B - Second\n" +
+		"Release/02. B - Second.flac\n"
+	if string(data) != expected {
+		t.Errorf("unexpected playlist:\n%s\nexpected:\n%s", data, expected)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/vbauerster/mpb/v8"
 	"io"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
@@ -14,35 +15,70 @@ import (
 	"syscall"
 	"unspok3n/beatportdl/config"
 	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/collection"
+	"unspok3n/beatportdl/internal/history"
+	"unspok3n/beatportdl/internal/logrotate"
+	"unspok3n/beatportdl/internal/retry"
+	"unspok3n/beatportdl/internal/transcode"
 )
 
 const (
-	configFilename = "beatportdl-config.yml"
-	cacheFilename  = "beatportdl-credentials.json"
-	errorFilename  = "beatportdl-err.log"
+	configFilename     = "beatportdl-config.yml"
+	cacheFilename      = "beatportdl-credentials.json"
+	historyFilename    = "beatportdl-history.jsonl"
+	watchlistFilename  = "beatportdl-watchlist.txt"
+	watchStateFilename = "beatportdl-watch-state.json"
+	errorFilename      = "beatportdl-err.log"
+
+	failedFilenameFormat = "failed-%s.txt"
 )
 
 type application struct {
 	config      *config.AppConfig
-	logFile     *os.File
+	logger      *slog.Logger
+	logFile     *logrotate.File
 	logWriter   io.Writer
 	ctx         context.Context
 	wg          sync.WaitGroup
 	downloadSem chan struct{}
 	globalSem   chan struct{}
 	pbp         *mpb.Progress
+	retry       *retry.Policy
+	encoder     transcode.Encoder
+	events      *eventWriter
+	report      *runReport
 
 	urls             []string
+	syncMode         bool
 	activeFiles      map[string]struct{}
 	activeFilesMutex sync.RWMutex
 
-	bp *beatport.Beatport
+	bp      *beatport.Beatport
+	history *history.Store
+	session *collection.Session
 }
 
 func main() {
-	cfg, cachePath, err := Setup()
+	selection, overrides, args, err := extractConfigFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+
+	flag.Usage = usage
+	quitFlag := flag.Bool("q", false, "Quit the main loop after finishing")
+	flag.CommandLine.Parse(args)
+
+	inputArgs := flag.Args()
+	command := ""
+	if len(inputArgs) > 0 && isCommand(inputArgs[0]) {
+		command = inputArgs[0]
+		inputArgs = inputArgs[1:]
+	}
+
+	cfg, cachePath, err := Setup(overrides, selection.withEnv(os.LookupEnv))
 	if err != nil {
-		fmt.Println(err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
 		Pause()
 	}
 
@@ -55,6 +91,18 @@ func main() {
 		ctx:         ctx,
 		logWriter:   os.Stdout,
 	}
+	if cfg.Output == "json" {
+		app.events = newEventWriter(os.Stdout)
+		app.logWriter = os.Stderr
+	}
+
+	if err := app.setupLogging(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		Pause()
+	}
+	if app.logFile != nil {
+		defer app.logFile.Close()
+	}
 
 	go func() {
 		sigCh := make(chan os.Signal, 1)
@@ -63,7 +111,7 @@ func main() {
 		<-sigCh
 
 		if len(app.urls) > 0 {
-			app.LogInfo("Shutdown signal received. Waiting for download workers to finish")
+			app.LogInfo("Shutdown signal received. Cancelling downloads")
 			cancel()
 
 			<-sigCh
@@ -72,41 +120,112 @@ func main() {
 		os.Exit(0)
 	}()
 
-	if cfg.WriteErrorLog {
-		logFilePath, _, err := FindErrorLogFile()
+	if command == "config" {
+		if err := app.printConfig(inputArgs); err != nil {
+			app.FatalError("config", err)
+		}
+		return
+	}
+
+	if cfg.DownloadHistory {
+		historyFilePath, _, err := FindHistoryFile()
 		if err != nil {
-			fmt.Println(err.Error())
-			Pause()
+			app.FatalError("history", err)
 		}
-		f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		store, err := history.Open(historyFilePath)
 		if err != nil {
-			panic(err)
+			app.FatalError("history", err)
 		}
-		app.logFile = f
-		defer f.Close()
+		app.history = store
+		defer store.Close()
+	}
+
+	if cfg.Format != "original" {
+		encoder, err := transcode.NewFFmpeg()
+		if err != nil {
+			app.FatalError("format", err)
+		}
+		app.encoder = encoder
+	}
+
+	if cfg.RekordboxXML != "" || cfg.TraktorNML != "" {
+		app.session = collection.NewSession()
+	}
+
+	app.retry = &retry.Policy{
+		MaxAttempts: cfg.RetryAttempts,
+		BaseDelay:   cfg.RetryDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+		OnRetry:     app.retryLogWrapper,
 	}
 
 	auth := beatport.NewAuth(cfg.Username, cfg.Password, cachePath)
 	bp := beatport.New(cfg.Proxy, auth)
+	bp.SetRetryPolicy(app.retry)
+	bp.SetLogger(app.logger)
+	bp.SetRateLimit(cfg.RateLimit, cfg.RateLimitBurst)
 
 	if err := auth.LoadCache(); err != nil {
-		if err := auth.Init(bp); err != nil {
+		if err := auth.InitContext(ctx, bp); err != nil {
 			app.FatalError("beatport", err)
 		}
 	}
 
 	app.bp = bp
-	quitFlag := flag.Bool("q", false, "Quit the main loop after finishing")
-
-	flag.Parse()
-	inputArgs := flag.Args()
 
-	for _, arg := range inputArgs {
-		if strings.HasSuffix(arg, ".txt") {
-			app.parseTextFile(arg)
-		} else {
-			app.urls = append(app.urls, arg)
+	switch command {
+	case "download":
+		app.queueArgs(inputArgs)
+		if len(app.urls) == 0 {
+			app.FatalError("download", ErrNoUrls)
 		}
+		app.downloadUrls()
+		return
+	case "search":
+		if err := app.searchCommand(inputArgs); err != nil {
+			app.FatalError("search", err)
+		}
+		return
+	case "info":
+		if err := app.info(inputArgs); err != nil {
+			app.FatalError("info", err)
+		}
+		return
+	case "watch":
+		if err := app.watch(inputArgs); err != nil {
+			app.FatalError("watch", err)
+		}
+		return
+	case "scan":
+		if err := app.scan(inputArgs); err != nil {
+			app.FatalError("scan", err)
+		}
+		return
+	case "retag":
+		if err := app.retag(inputArgs); err != nil {
+			app.FatalError("retag", err)
+		}
+		return
+	case "reorganize":
+		if err := app.reorganize(inputArgs); err != nil {
+			app.FatalError("reorganize", err)
+		}
+		return
+	case "sync":
+		app.syncMode = true
+		app.queueArgs(inputArgs)
+		if len(app.urls) == 0 {
+			app.FatalError("sync", ErrNoSyncUrls)
+		}
+		app.downloadUrls()
+		return
+	}
+
+	app.queueArgs(inputArgs)
+
+	// The prompts would mix with the events on stdout
+	if app.events != nil && len(app.urls) == 0 {
+		app.FatalError("download", ErrNoUrls)
 	}
 
 	for {
@@ -114,23 +233,51 @@ func main() {
 			app.mainPrompt()
 		}
 
+		app.downloadUrls()
+
+		if *quitFlag || app.events != nil || ctx.Err() != nil {
+			break
+		}
+
+		app.urls = []string{}
+	}
+}
+
+// downloadUrls handles every queued URL, waits for all downloads to finish
+// and returns the report of the batch.
+func (app *application) downloadUrls() *runReport {
+	if app.events == nil {
 		app.pbp = mpb.New(mpb.WithAutoRefresh(), mpb.WithOutput(color.Output))
 		app.logWriter = app.pbp
-		app.activeFiles = make(map[string]struct{}, len(app.urls))
+	}
+	app.activeFiles = make(map[string]struct{}, len(app.urls))
+	app.report = newRunReport()
 
-		for _, url := range app.urls {
-			app.globalWorker(func() {
-				app.handleUrl(url)
-			})
-		}
+	for _, url := range app.urls {
+		app.globalWorker(func() {
+			app.handleUrl(url)
+		})
+	}
 
-		app.wg.Wait()
+	app.wg.Wait()
+	if app.events == nil {
 		app.pbp.Shutdown()
+		app.logWriter = os.Stdout
+	}
 
-		if *quitFlag || ctx.Err() != nil {
-			break
-		}
+	report := app.report
+	app.exportCollections()
+	app.finishReport()
+	return report
+}
 
-		app.urls = []string{}
+// queueArgs adds URLs from the command line, reading text files line by line.
+func (app *application) queueArgs(args []string) {
+	for _, arg := range args {
+		if strings.HasSuffix(arg, ".txt") {
+			app.parseTextFile(arg)
+		} else {
+			app.urls = append(app.urls, arg)
+		}
 	}
 }
@@ -1,24 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
-	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/remux"
 
 	"github.com/google/uuid"
 	"github.com/grafov/m3u8"
-	"github.com/vbauerster/mpb/v8"
 )
 
 type StreamKey struct {
@@ -26,70 +27,224 @@ type StreamKey struct {
 	IV    []byte
 }
 
-func getStreamSegments(stream string) (*[]string, *StreamKey, error) {
-	resp, err := http.Get(stream)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+// streamSegment is a media segment of an HLS stream together with the key
+// it is encrypted with, or a nil key if it is not encrypted.
+type streamSegment struct {
+	URL      string
+	Key      *StreamKey
+	Start    time.Duration
+	Duration time.Duration
+}
+
+var (
+	ErrUnsupportedEncryption = errors.New("unsupported stream encryption method")
+	ErrNoStreamVariants      = errors.New("master playlist has no playable variants")
+	ErrNestedMasterPlaylist  = errors.New("master playlist variant is a master playlist")
+	ErrNoPreview             = errors.New("track has no preview")
+)
+
+// getMediaPlaylist fetches the playlist at streamUrl. A master playlist is
+// resolved to its variant with the highest bandwidth. The returned URL is the
+// one of the media playlist, which segment and key URIs are relative to.
+func (app *application) getMediaPlaylist(ctx context.Context, streamUrl *url.URL) (*m3u8.MediaPlaylist, *url.URL, error) {
+	for depth := 0; ; depth++ {
+		playlistData, err := app.httpGet(ctx, streamUrl.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		playlist, _, err := m3u8.DecodeFrom(bytes.NewReader(playlistData), true)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch playlist := playlist.(type) {
+		case *m3u8.MediaPlaylist:
+			return playlist, streamUrl, nil
+		case *m3u8.MasterPlaylist:
+			if depth > 0 {
+				return nil, nil, ErrNestedMasterPlaylist
+			}
+			var best *m3u8.Variant
+			for _, variant := range playlist.Variants {
+				if variant == nil || variant.Iframe {
+					continue
+				}
+				if best == nil || variant.Bandwidth > best.Bandwidth {
+					best = variant
+				}
+			}
+			if best == nil {
+				return nil, nil, ErrNoStreamVariants
+			}
+			streamUrl, err = streamUrl.Parse(best.URI)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("unexpected playlist type %T", playlist)
+		}
 	}
-	playlist, _, err := m3u8.DecodeFrom(resp.Body, true)
+}
+
+func (app *application) getStreamSegments(ctx context.Context, stream string) ([]streamSegment, error) {
+	streamUrl, err := url.Parse(stream)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	u, err := url.Parse(stream)
+	media, streamUrl, err := app.getMediaPlaylist(ctx, streamUrl)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	base := u.Scheme + "://" + u.Host + path.Dir(u.Path) + "/"
-	media := playlist.(*m3u8.MediaPlaylist)
-	var segments []string
-	var streamKey StreamKey
+
+	// Keys are usually shared by many segments, and only fetched once
+	keys := make(map[string][]byte)
+	var key *m3u8.Key
+	var segments []streamSegment
+	var start time.Duration
 	for i, segment := range media.Segments {
 		if segment == nil {
 			break
 		}
-		if i == 0 {
-			req, err := http.Get(base + segment.Key.URI)
-			if err != nil {
-				return nil, nil, err
-			}
-			defer req.Body.Close()
-			if req.StatusCode != http.StatusOK {
-				return nil, nil, fmt.Errorf("get stream key failed with status code: %d", req.StatusCode)
-			}
-			keyBytes, err := io.ReadAll(req.Body)
-			if err != nil {
-				return nil, nil, fmt.Errorf("read stream key: %v", err)
-			}
-			ivBytes, err := hex.DecodeString(strings.TrimPrefix(segment.Key.IV, "0x"))
-			if err != nil {
-				return nil, nil, fmt.Errorf("decode stream iv: %v", err)
+		// EXT-X-KEY applies to every segment up to the next one
+		if segment.Key != nil {
+			key = segment.Key
+		}
+
+		segmentUrl, err := streamUrl.Parse(segment.URI)
+		if err != nil {
+			return nil, fmt.Errorf("parse segment url: %v", err)
+		}
+		duration := time.Duration(segment.Duration * float64(time.Second))
+		streamSegment := streamSegment{
+			URL:      segmentUrl.String(),
+			Start:    start,
+			Duration: duration,
+		}
+		start += duration
+
+		if key != nil {
+			switch strings.ToUpper(key.Method) {
+			case "", "NONE":
+			case "AES-128":
+				streamSegment.Key, err = app.getSegmentKey(ctx, streamUrl, key, media.SeqNo+uint64(i), keys)
+				if err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncryption, key.Method)
 			}
-			streamKey.Value = keyBytes
-			streamKey.IV = ivBytes
 		}
-		segments = append(segments, base+segment.URI)
+		segments = append(segments, streamSegment)
+	}
+
+	return segments, nil
+}
+
+// getSegmentKey returns the AES-128 key of a segment. Without an explicit IV
+// the IV is the media sequence number of the segment as a 128-bit big-endian
+// integer, as the HLS specification requires.
+func (app *application) getSegmentKey(ctx context.Context, streamUrl *url.URL, key *m3u8.Key, sequence uint64, keys map[string][]byte) (*StreamKey, error) {
+	keyUrl, err := streamUrl.Parse(key.URI)
+	if err != nil {
+		return nil, fmt.Errorf("parse stream key url: %v", err)
+	}
+	value, found := keys[keyUrl.String()]
+	if !found {
+		value, err = app.httpGet(ctx, keyUrl.String())
+		if err != nil {
+			return nil, fmt.Errorf("get stream key: %v", err)
+		}
+		if len(value) != aes.BlockSize {
+			return nil, fmt.Errorf("get stream key: invalid key length %d", len(value))
+		}
+		keys[keyUrl.String()] = value
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if key.IV != "" {
+		ivHex := strings.TrimPrefix(strings.TrimPrefix(key.IV, "0x"), "0X")
+		decoded, err := hex.DecodeString(ivHex)
+		if err != nil || len(decoded) > aes.BlockSize {
+			return nil, fmt.Errorf("decode stream iv: invalid iv %q", key.IV)
+		}
+		copy(iv[aes.BlockSize-len(decoded):], decoded)
+	} else {
+		binary.BigEndian.PutUint64(iv[8:], sequence)
+	}
+
+	return &StreamKey{Value: value, IV: iv}, nil
+}
+
+// previewSegments selects the segments covering the preview window of a
+// track and returns the clip of the preview relative to the first of them.
+func previewSegments(segments []streamSegment, stream *beatport.TrackStream) ([]streamSegment, remux.Clip, error) {
+	previewStart := time.Duration(stream.SampleStartMs) * time.Millisecond
+	previewEnd := time.Duration(stream.SampleEndMs) * time.Millisecond
+	if previewEnd <= previewStart {
+		return nil, remux.Clip{}, ErrNoPreview
+	}
+
+	var selected []streamSegment
+	for _, segment := range segments {
+		if segment.Start+segment.Duration > previewStart && segment.Start < previewEnd {
+			selected = append(selected, segment)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, remux.Clip{}, ErrNoPreview
 	}
 
-	return &segments, &streamKey, nil
+	offset := selected[0].Start
+	return selected, remux.Clip{
+		Start: previewStart - offset,
+		End:   previewEnd - offset,
+	}, nil
 }
 
+var (
+	ErrInvalidSegment = errors.New("invalid encrypted segment")
+)
+
+// decryptSegment decrypts an AES-128 segment in place and strips its PKCS#7
+// padding.
 func decryptSegment(segment []byte, key StreamKey) ([]byte, error) {
 	block, err := aes.NewCipher(key.Value)
 	if err != nil {
 		return nil, err
 	}
+	if len(segment) == 0 || len(segment)%aes.BlockSize != 0 {
+		return nil, ErrInvalidSegment
+	}
 	cbc := cipher.NewCBCDecrypter(block, key.IV)
-	decrypted := make([]byte, len(segment))
-	cbc.CryptBlocks(decrypted, segment)
-	padding := decrypted[len(decrypted)-1]
-	return decrypted[:len(decrypted)-int(padding)], nil
+	cbc.CryptBlocks(segment, segment)
+	padding := int(segment[len(segment)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, ErrInvalidSegment
+	}
+	return segment[:len(segment)-padding], nil
+}
+
+func (app *application) downloadSegment(ctx context.Context, segment streamSegment) ([]byte, error) {
+	segBytes, err := app.httpGet(ctx, segment.URL)
+	if err != nil {
+		return nil, err
+	}
+	if segment.Key == nil {
+		return segBytes, nil
+	}
+	return decryptSegment(segBytes, *segment.Key)
 }
 
-func (app *application) downloadSegments(path string, segmentUrls []string, key StreamKey, pbPrefix string) (string, error) {
+type segmentResult struct {
+	data []byte
+	err  error
+}
+
+// downloadSegments fetches and decrypts the segments with up to
+// MaxSegmentWorkers concurrent requests and writes them to a temporary file in
+// playlist order. Workers never get more than two segments per worker ahead
+// of the writer, which bounds the memory held by finished segments.
+func (app *application) downloadSegments(ctx context.Context, path string, segments []streamSegment, progress *downloadProgress) (string, error) {
 	tempFileName := uuid.New().String()
 	path = filepath.Join(path, tempFileName)
 	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0755)
@@ -97,39 +252,73 @@ func (app *application) downloadSegments(path string, segmentUrls []string, key
 		return "", err
 	}
 
-	var bar *mpb.Bar
+	var bar progressBar
 	var start time.Time
-	if pbPrefix != "" {
+	if progress != nil {
 		start = time.Now()
-		total := len(segmentUrls)
-		bar = app.pbp.AddBar(int64(total), ProgressBarOptions(pbPrefix)...)
+		total := len(segments)
+		bar = app.addProgressBar(progress, int64(total), "segments")
 	}
 
-	for _, segmentUrl := range segmentUrls {
-		if err := func() error {
-			resp, err := http.Get(segmentUrl)
-			if err != nil {
-				return err
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				return errors.New(resp.Status)
+	workers := min(app.config.MaxSegmentWorkers, len(segments))
+	results := make([]chan segmentResult, len(segments))
+	for i := range results {
+		results[i] = make(chan segmentResult, 1)
+	}
+	window := make(chan struct{}, 2*workers)
+	jobs := make(chan int)
+
+	wg := sync.WaitGroup{}
+	ctx, cancel := context.WithCancel(ctx)
+	defer wg.Wait()
+	defer cancel()
+
+	go func() {
+		defer close(jobs)
+		for i := range segments {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
 			}
-			segBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return err
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
 			}
-			decSegBytes, err := decryptSegment(segBytes, key)
-			if err != nil {
-				return err
+		}
+	}()
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data, err := app.downloadSegment(ctx, segments[i])
+				results[i] <- segmentResult{data: data, err: err}
 			}
-			if _, err := file.Write(decSegBytes); err != nil {
-				return err
+		}()
+	}
+
+	for i := range segments {
+		var result segmentResult
+		select {
+		case result = <-results[i]:
+		case <-ctx.Done():
+			result.err = ctx.Err()
+		}
+		if result.err == nil {
+			_, result.err = file.Write(result.data)
+		}
+		if result.err != nil {
+			if bar != nil {
+				bar.Abort(true)
 			}
-			return nil
-		}(); err != nil {
-			return "", err
+			file.Close()
+			os.Remove(path)
+			return "", fmt.Errorf("segment %d: %w", i, result.err)
 		}
+		<-window
 		if bar != nil {
 			bar.EwmaIncrInt64(1, time.Since(start))
 		}
@@ -141,16 +330,18 @@ func (app *application) downloadSegments(path string, segmentUrls []string, key
 	return path, nil
 }
 
-func remuxToM4A(input, output string) error {
-	cmd := exec.Command("ffmpeg",
-		"-i", input,
-		"-map_metadata", "-1",
-		"-c:a", "copy",
-		output,
-	)
-	err := cmd.Run()
+func remuxToM4A(input, output string, clip remux.Clip) error {
+	data, err := os.ReadFile(input)
 	if err != nil {
-		return fmt.Errorf("ffmpeg: %w", err)
+		return err
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := remux.WriteM4A(file, data, clip); err != nil {
+		file.Close()
+		return err
 	}
-	return nil
+	return file.Close()
 }
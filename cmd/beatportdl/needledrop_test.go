@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/retry"
+)
+
+func encryptSegment(t *testing.T, plain []byte, key StreamKey) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, key.IV).CryptBlocks(padded, padded)
+	return padded
+}
+
+func TestDownloadSegmentsOrder(t *testing.T) {
+	key := StreamKey{Value: bytes.Repeat([]byte{1}, 16), IV: bytes.Repeat([]byte{2}, 16)}
+
+	var segments [][]byte
+	var expected []byte
+	for i := 0; i < 20; i++ {
+		plain := []byte(strings.Repeat(strconv.Itoa(i), 100+i))
+		expected = append(expected, plain...)
+		segments = append(segments, encryptSegment(t, plain, key))
+	}
+
+	var failed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		// Earlier segments finish last, and one segment fails once
+		time.Sleep(time.Duration(20-i) * time.Millisecond)
+		if i == 7 && !failed.Swap(true) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(segments[i])
+	}))
+	defer server.Close()
+
+	var streamSegments []streamSegment
+	for i := range segments {
+		streamSegments = append(streamSegments, streamSegment{
+			URL: fmt.Sprintf("%s/%d", server.URL, i),
+			Key: &key,
+		})
+	}
+
+	app := &application{
+		config: &config.AppConfig{MaxSegmentWorkers: 4},
+		retry:  &retry.Policy{MaxAttempts: 2},
+	}
+	path, err := app.downloadSegments(context.Background(), t.TempDir(), streamSegments, nil)
+	if err != nil {
+		t.Fatalf("downloadSegments() failed: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Error("segments were not written in playlist order")
+	}
+	if !failed.Load() {
+		t.Error("failed segment was not retried")
+	}
+}
+
+func TestGetStreamSegments(t *testing.T) {
+	var keyRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=64000\nlow/index.m3u8\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=128000\nhigh/index.m3u8\n")
+	})
+	mux.HandleFunc("/high/index.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:5\n"+
+			"#EXT-X-KEY:METHOD=AES-128,URI=\"key1\",IV=0x0102\n"+
+			"#EXTINF:10,\nseg0.ts\n"+
+			"#EXTINF:10,\nseg1.ts\n"+
+			"#EXT-X-KEY:METHOD=AES-128,URI=\"/keys/key2\"\n"+
+			"#EXTINF:10,\nseg2.ts\n"+
+			"#EXT-X-KEY:METHOD=NONE\n"+
+			"#EXTINF:10,\nseg3.ts\n"+
+			"#EXT-X-ENDLIST\n")
+	})
+	mux.HandleFunc("/high/key1", func(w http.ResponseWriter, r *http.Request) {
+		keyRequests.Add(1)
+		w.Write(bytes.Repeat([]byte{1}, 16))
+	})
+	mux.HandleFunc("/keys/key2", func(w http.ResponseWriter, r *http.Request) {
+		keyRequests.Add(1)
+		w.Write(bytes.Repeat([]byte{2}, 16))
+	})
+	mux.HandleFunc("/sample-aes.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:10\n"+
+			"#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"key\"\n"+
+			"#EXTINF:10,\nseg0.ts\n#EXT-X-ENDLIST\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	app := &application{}
+	segments, err := app.getStreamSegments(context.Background(), server.URL+"/master.m3u8")
+	if err != nil {
+		t.Fatalf("getStreamSegments() failed: %v", err)
+	}
+	if len(segments) != 4 {
+		t.Fatalf("expected 4 segments, got %d", len(segments))
+	}
+	if segments[1].URL != server.URL+"/high/seg1.ts" {
+		t.Errorf("unexpected segment url %s", segments[1].URL)
+	}
+	if keyRequests.Load() != 2 {
+		t.Errorf("expected keys to be fetched once each, got %d requests", keyRequests.Load())
+	}
+
+	explicitIV := append(make([]byte, 14), 1, 2)
+	for i := 0; i < 2; i++ {
+		if key := segments[i].Key; key == nil || key.Value[0] != 1 || !bytes.Equal(key.IV, explicitIV) {
+			t.Errorf("segment %d: unexpected key %+v", i, key)
+		}
+	}
+	// Media sequence 5 + index 2
+	sequenceIV := append(make([]byte, 15), 7)
+	if key := segments[2].Key; key == nil || key.Value[0] != 2 || !bytes.Equal(key.IV, sequenceIV) {
+		t.Errorf("segment 2: unexpected key %+v", key)
+	}
+	if segments[3].Key != nil {
+		t.Errorf("segment 3 should not be encrypted")
+	}
+
+	if _, err := app.getStreamSegments(context.Background(), server.URL+"/sample-aes.m3u8"); !errors.Is(err, ErrUnsupportedEncryption) {
+		t.Errorf("expected ErrUnsupportedEncryption, got %v", err)
+	}
+}
+
+func TestPreviewSegments(t *testing.T) {
+	var segments []streamSegment
+	for i := 0; i < 10; i++ {
+		segments = append(segments, streamSegment{
+			URL:      strconv.Itoa(i),
+			Start:    time.Duration(i) * 10 * time.Second,
+			Duration: 10 * time.Second,
+		})
+	}
+
+	selected, clip, err := previewSegments(segments, &beatport.TrackStream{SampleStartMs: 25000, SampleEndMs: 55000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 4 || selected[0].URL != "2" || selected[3].URL != "5" {
+		t.Errorf("unexpected segments %+v", selected)
+	}
+	if clip.Start != 5*time.Second || clip.End != 35*time.Second {
+		t.Errorf("unexpected clip %+v", clip)
+	}
+
+	if _, _, err := previewSegments(segments, &beatport.TrackStream{}); !errors.Is(err, ErrNoPreview) {
+		t.Errorf("expected ErrNoPreview, got %v", err)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unspok3n/beatportdl/internal/taglib"
+)
+
+const (
+	reorganizeLogPrefix = "beatportdl-reorganize-"
+	coverFilename       = "cover.jpg"
+)
+
+var (
+	ErrMoveTargetExists = errors.New("target file already exists")
+)
+
+// reorganizeMove is an undo log entry. Root is the library directory the file
+// was found in, empty directories are removed up to it.
+type reorganizeMove struct {
+	Root string `json:"root"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type reorganizeFile struct {
+	root string
+	path string
+	// playlist is the playlist or chart folder the file is in, if any
+	playlist string
+	target   string
+}
+
+// reorganize moves existing downloads to the paths the current naming
+// templates give them, as if their releases were downloaded again. Tracks are
+// identified like in retag, the release by the tag mapped to release_id if
+// there is one. Every move is written to an undo log in the working directory.
+func (app *application) reorganize(args []string) error {
+	fs := flag.NewFlagSet("reorganize", flag.ExitOnError)
+	dryRun := fs.Bool("n", false, "Show the new paths without moving anything")
+	undo := fs.String("undo", "", "Move the files back using the undo log of an earlier run")
+	fs.Parse(args)
+
+	if *undo != "" {
+		return app.undoReorganize(*undo)
+	}
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{app.config.DownloadsDirectory}
+	}
+
+	var files []*reorganizeFile
+	for _, dir := range dirs {
+		root, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && d.Name() == syncTrashDirectory {
+					return filepath.SkipDir
+				}
+				// synced playlists keep the paths of their tracks in the manifest
+				if fileExists(filepath.Join(path, syncManifestFilename)) {
+					app.LogInfo(fmt.Sprintf("skipping synced playlist %s", path))
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if slices.Contains(scanExtensions, strings.ToLower(filepath.Ext(path))) {
+				files = append(files, &reorganizeFile{root: root, path: path, playlist: playlistDirectory(path, root)})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	idPattern := trackIDPattern(app.config.TrackFileTemplate, app.config.WhitespaceCharacter)
+	releases := newReleaseCache()
+	wg := sync.WaitGroup{}
+	for _, file := range files {
+		app.downloadWorker(&wg, func() {
+			target, err := app.reorganizeTarget(file, idPattern, releases)
+			if err != nil {
+				app.errorLogWrapper(file.path, "reorganize", err)
+				return
+			}
+			file.target = target
+		})
+	}
+	wg.Wait()
+
+	if app.ctx.Err() != nil {
+		return app.ctx.Err()
+	}
+
+	moves := planMoves(files)
+	if *dryRun {
+		for _, move := range moves {
+			app.LogInfo(fmt.Sprintf("%s\n  -> %s", move.From, move.To))
+		}
+		app.LogInfo(fmt.Sprintf("%d of %d files would be moved (dry run)", len(moves), len(files)))
+		return nil
+	}
+	if len(moves) == 0 {
+		app.LogInfo(fmt.Sprintf("all %d files are in place", len(files)))
+		return nil
+	}
+
+	logPath, err := WorkingDirFilePath(reorganizeLogPrefix + time.Now().Format("20060102-150405") + ".jsonl")
+	if err != nil {
+		return err
+	}
+	log, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("create undo log: %w", err)
+	}
+	defer log.Close()
+
+	moved := 0
+	for _, move := range append(moves, coverMoves(moves)...) {
+		if err := app.applyMove(move, log); err != nil {
+			app.errorLogWrapper(move.From, "move file", err)
+			continue
+		}
+		if slices.Contains(scanExtensions, strings.ToLower(filepath.Ext(move.From))) {
+			moved++
+		}
+	}
+
+	app.LogInfo(fmt.Sprintf("%d of %d files moved, undo with: reorganize -undo %s", moved, len(files), logPath))
+	return nil
+}
+
+// reorganizeTarget returns the path a file would be saved to by downloading
+// its release into root. Files of playlists and charts stay in their folder
+// and are only renamed.
+func (app *application) reorganizeTarget(f *reorganizeFile, idPattern *regexp.Regexp, releases *releaseCache) (string, error) {
+	path := f.path
+	mappings, id3v2 := app.tagMappings(strings.ToLower(filepath.Ext(path)))
+
+	file, err := taglib.Read(path)
+	if err != nil {
+		return "", err
+	}
+	var trackTag, releaseTag string
+	if property, ok := mappings["track_id"]; ok {
+		trackTag = readTag(file, property, id3v2)
+	}
+	if property, ok := mappings["release_id"]; ok {
+		releaseTag = readTag(file, property, id3v2)
+	}
+	file.Close()
+
+	id, err := fileTrackID(path, trackTag, idPattern)
+	if err != nil {
+		return "", err
+	}
+	track, err := app.bp.GetTrackContext(app.ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("fetch track: %w", err)
+	}
+
+	releaseID := track.Release.ID
+	if id, err := strconv.ParseInt(strings.TrimSpace(releaseTag), 10, 64); err == nil {
+		releaseID = id
+	}
+	release, err := releases.get(app, releaseID)
+	if err != nil {
+		return "", fmt.Errorf("fetch track release: %w", err)
+	}
+	track.Release = *release.release
+
+	dir := filepath.Dir(path)
+	if f.playlist == "" {
+		dir = app.downloadsDirectoryPath(f.root, release.release)
+	}
+	quality := ""
+	if strings.HasSuffix(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), previewFileSuffix) {
+		quality = "preview"
+	}
+	return filepath.Join(dir, app.saveFilename(track, quality)+filepath.Ext(path)), nil
+}
+
+// playlistDirectory returns the closest folder of path below root that holds
+// the .m3u8 of a downloaded playlist or chart, or an empty string if there is
+// none. Release playlists and playlists in root itself don't count.
+func playlistDirectory(path, root string) string {
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		playlists, _ := filepath.Glob(filepath.Join(dir, "*"+playlistFileExtension))
+		for _, playlist := range playlists {
+			context, _ := readPlaylistContext(playlist)
+			if context == playlistContextPlaylist || context == playlistContextChart {
+				return dir
+			}
+		}
+	}
+	return ""
+}
+
+// updatePlaylists points the entries for a moved file in the playlists of the
+// folders around it, up to root, to its new path.
+func updatePlaylists(move reorganizeMove) error {
+	var dirs []string
+	for _, path := range []string{move.From, move.To} {
+		for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+			if !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+			if !strings.HasPrefix(dir, move.Root+string(filepath.Separator)) {
+				break
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		playlists, err := filepath.Glob(filepath.Join(dir, "*"+playlistFileExtension))
+		if err != nil {
+			return err
+		}
+		for _, playlist := range playlists {
+			err := rewritePlaylistFile(playlist, dir, func(location string) string {
+				if location == move.From {
+					return move.To
+				}
+				return location
+			})
+			if err != nil {
+				return fmt.Errorf("update playlist %s: %w", filepath.Base(playlist), err)
+			}
+		}
+	}
+	return nil
+}
+
+// rewritePlaylistFile passes the location of every entry of the playlist at
+// path, relative to dir, through rewrite and writes the result relative to
+// the folder of path.
+func rewritePlaylistFile(path, dir string, rewrite func(location string) string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	changed := false
+	for i, line := range lines {
+		entry := strings.TrimSuffix(line, "\r")
+		if entry == "" || strings.HasPrefix(entry, "#") || filepath.IsAbs(entry) {
+			continue
+		}
+		location := rewrite(filepath.Join(dir, filepath.FromSlash(entry)))
+		relPath, err := filepath.Rel(filepath.Dir(path), location)
+		if err != nil {
+			return err
+		}
+		if relPath = filepath.ToSlash(relPath); relPath != entry {
+			lines[i] = relPath + strings.TrimPrefix(line, entry)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// planMoves returns the moves of the files whose target differs from their
+// path. Targets that are taken by an existing file or by another move get a
+// " (n)" suffix, like duplicate downloads.
+func planMoves(files []*reorganizeFile) []reorganizeMove {
+	sorted := slices.Clone(files)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].path < sorted[j].path
+	})
+
+	taken := make(map[string]bool)
+	for _, file := range sorted {
+		if file.target == "" || file.target == file.path {
+			taken[file.path] = true
+		}
+	}
+
+	var moves []reorganizeMove
+	for _, file := range sorted {
+		if file.target == "" || file.target == file.path {
+			continue
+		}
+		target := file.target
+		ext := filepath.Ext(target)
+		base := strings.TrimSuffix(target, ext)
+		for i := 1; taken[target] || (fileExists(target) && !sameFile(file.path, target)); i++ {
+			target = fmt.Sprintf("%s (%d)%s", base, i, ext)
+		}
+		taken[target] = true
+		if target == file.path {
+			continue
+		}
+		moves = append(moves, reorganizeMove{Root: file.root, From: file.path, To: target})
+	}
+	return moves
+}
+
+// coverMoves moves the cover.jpg and the release playlists of every directory
+// whose tracks were all moved to the same directory along with them.
+func coverMoves(moves []reorganizeMove) []reorganizeMove {
+	targets := make(map[string]string)
+	var dirs []string
+	for _, move := range moves {
+		from, to := filepath.Dir(move.From), filepath.Dir(move.To)
+		target, seen := targets[from]
+		if !seen {
+			dirs = append(dirs, from)
+		} else if target != to {
+			to = ""
+		}
+		targets[from] = to
+	}
+
+	var covers []reorganizeMove
+	for _, dir := range dirs {
+		to := targets[dir]
+		if to == "" || to == dir {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		// tracks that stay behind keep the cover
+		remaining := slices.ContainsFunc(entries, func(e os.DirEntry) bool {
+			path := filepath.Join(dir, e.Name())
+			return slices.Contains(scanExtensions, strings.ToLower(filepath.Ext(path))) &&
+				!slices.ContainsFunc(moves, func(m reorganizeMove) bool { return m.From == path })
+		})
+		if remaining {
+			continue
+		}
+		root := ""
+		for _, move := range moves {
+			if filepath.Dir(move.From) == dir {
+				root = move.Root
+				break
+			}
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if name != coverFilename && strings.ToLower(filepath.Ext(name)) != playlistFileExtension {
+				continue
+			}
+			from := filepath.Join(dir, name)
+			if context, _ := readPlaylistContext(from); context == playlistContextPlaylist || context == playlistContextChart {
+				continue
+			}
+			if fileExists(filepath.Join(to, name)) {
+				continue
+			}
+			covers = append(covers, reorganizeMove{Root: root, From: from, To: filepath.Join(to, name)})
+		}
+	}
+	return covers
+}
+
+// applyMove moves a file, records the move in the undo log and updates the
+// download history and the playlists that refer to it. A moved playlist gets
+// its entries rewritten relative to its new folder. Moving it back with the
+// reversed move also restores the playlists.
+func (app *application) applyMove(move reorganizeMove, log *os.File) error {
+	if fileExists(move.To) && !sameFile(move.From, move.To) {
+		return ErrMoveTargetExists
+	}
+	if err := CreateDirectory(filepath.Dir(move.To)); err != nil {
+		return err
+	}
+	if err := os.Rename(move.From, move.To); err != nil {
+		return err
+	}
+
+	if log != nil {
+		data, err := json.Marshal(move)
+		if err != nil {
+			return err
+		}
+		if _, err := log.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("write undo log: %w", err)
+		}
+	}
+
+	if err := app.history.Move(move.From, move.To); err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(move.To)) == playlistFileExtension {
+		err := rewritePlaylistFile(move.To, filepath.Dir(move.From), func(location string) string { return location })
+		if err != nil {
+			return fmt.Errorf("update playlist %s: %w", filepath.Base(move.To), err)
+		}
+	} else if err := updatePlaylists(move); err != nil {
+		return err
+	}
+
+	removeEmptyDirectories(filepath.Dir(move.From), move.Root)
+	return nil
+}
+
+// undoReorganize moves the files listed in an undo log back, last move first.
+func (app *application) undoReorganize(logPath string) error {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var moves []reorganizeMove
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var move reorganizeMove
+		if err := json.Unmarshal(scanner.Bytes(), &move); err != nil {
+			continue
+		}
+		moves = append(moves, move)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read undo log: %w", err)
+	}
+
+	restored := 0
+	for i := len(moves) - 1; i >= 0; i-- {
+		move := moves[i]
+		back := reorganizeMove{Root: move.Root, From: move.To, To: move.From}
+		if err := app.applyMove(back, nil); err != nil {
+			app.errorLogWrapper(back.From, "move file back", err)
+			continue
+		}
+		restored++
+	}
+
+	app.LogInfo(fmt.Sprintf("%d of %d files moved back", restored, len(moves)))
+	return nil
+}
+
+// removeEmptyDirectories removes dir and its parents up to root while they
+// are empty.
+func removeEmptyDirectories(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func sameFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanMoves(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "Release", "01. A - Track.flac")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*reorganizeFile{
+		{root: root, path: filepath.Join(root, "b.flac"), target: existing},
+		{root: root, path: filepath.Join(root, "a.flac"), target: existing},
+		{root: root, path: existing, target: existing},
+		{root: root, path: filepath.Join(root, "c.flac"), target: filepath.Join(root, "Release", "02. A - Other.flac")},
+		{root: root, path: filepath.Join(root, "d.flac")},
+	}
+
+	moves := planMoves(files)
+	expected := []reorganizeMove{
+		{Root: root, From: filepath.Join(root, "a.flac"), To: filepath.Join(root, "Release", "01. A - Track (1).flac")},
+		{Root: root, From: filepath.Join(root, "b.flac"), To: filepath.Join(root, "Release", "01. A - Track (2).flac")},
+		{Root: root, From: filepath.Join(root, "c.flac"), To: filepath.Join(root, "Release", "02. A - Other.flac")},
+	}
+	if len(moves) != len(expected) {
+		t.Fatalf("planMoves() = %+v, want %+v", moves, expected)
+	}
+	for i := range expected {
+		if moves[i] != expected[i] {
+			t.Errorf("move %d = %+v, want %+v", i, moves[i], expected[i])
+		}
+	}
+}
+
+func TestRemoveEmptyDirectories(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Label", "Release")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	removeEmptyDirectories(dir, root)
+
+	if fileExists(filepath.Join(root, "Label")) {
+		t.Error("empty parent directory was not removed")
+	}
+	if !fileExists(root) {
+		t.Error("root directory was removed")
+	}
+}
+
+func TestReorganizePlaylistFolder(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Top 10")
+	from := filepath.Join(dir, "A - Track.flac")
+	to := filepath.Join(dir, "01. A - Track (Original Mix).flac")
+	playlistPath := filepath.Join(dir, "Top 10.m3u8")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(from, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	playlist := "#EXTM3U\n#BEATPORTDL-CONTEXT:playlist\n#EXTINF:300,A - Track\nA - Track.flac\n#EXTINF:280,B - Other\nB - Other.flac\n"
+	if err := os.WriteFile(playlistPath, []byte(playlist), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := playlistDirectory(from, root); got != dir {
+		t.Errorf("playlistDirectory() = %q, want %q", got, dir)
+	}
+	if got := playlistDirectory(filepath.Join(root, "Release", "01. B - Other.flac"), root); got != "" {
+		t.Errorf("playlistDirectory() of a release folder = %q", got)
+	}
+
+	app := &application{}
+	if err := app.applyMove(reorganizeMove{Root: root, From: from, To: to}, nil); err != nil {
+		t.Fatalf("applyMove() failed: %v", err)
+	}
+	data, err := os.ReadFile(playlistPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "#EXTM3U\n#BEATPORTDL-CONTEXT:playlist\n#EXTINF:300,A - Track\n01. A - Track (Original Mix).flac\n#EXTINF:280,B - Other\nB - Other.flac\n"
+	if string(data) != expected {
+		t.Errorf("playlist after move = %q, want %q", data, expected)
+	}
+
+	if err := app.applyMove(reorganizeMove{Root: root, From: to, To: from}, nil); err != nil {
+		t.Fatalf("applyMove() back failed: %v", err)
+	}
+	if data, _ := os.ReadFile(playlistPath); string(data) != playlist {
+		t.Errorf("playlist after moving back = %q, want %q", data, playlist)
+	}
+}
+
+func TestReorganizeReleaseFolder(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Strobe")
+	from := filepath.Join(dir, "01. deadmau5 - Strobe.flac")
+	to := filepath.Join(root, "mau5trap", "Strobe", "01. deadmau5 - Strobe (Original Mix).flac")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(from, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	release := "#EXTM3U\n#BEATPORTDL-CONTEXT:release\n#EXTINF:600,deadmau5 - Strobe\n01. deadmau5 - Strobe.flac\n"
+	if err := os.WriteFile(filepath.Join(dir, "Strobe.m3u8"), []byte(release), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a playlist downloaded without sort_by_context ends up in root
+	if err := os.WriteFile(filepath.Join(root, "Top 10.m3u8"), []byte("#EXTM3U\n#BEATPORTDL-CONTEXT:playlist\nStrobe/01. deadmau5 - Strobe.flac\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := playlistDirectory(from, root); got != "" {
+		t.Errorf("playlistDirectory() of a release folder = %q", got)
+	}
+
+	moves := []reorganizeMove{{Root: root, From: from, To: to}}
+	covers := coverMoves(moves)
+	expected := []reorganizeMove{{Root: root, From: filepath.Join(dir, "Strobe.m3u8"), To: filepath.Join(filepath.Dir(to), "Strobe.m3u8")}}
+	if len(covers) != 1 || covers[0] != expected[0] {
+		t.Fatalf("coverMoves() = %+v, want %+v", covers, expected)
+	}
+
+	app := &application{}
+	for _, move := range append(moves, covers...) {
+		if err := app.applyMove(move, nil); err != nil {
+			t.Fatalf("applyMove() failed: %v", err)
+		}
+	}
+	if fileExists(dir) {
+		t.Errorf("release folder was not removed")
+	}
+	data, _ := os.ReadFile(expected[0].To)
+	if want := "#EXTM3U\n#BEATPORTDL-CONTEXT:release\n#EXTINF:600,deadmau5 - Strobe\n01. deadmau5 - Strobe (Original Mix).flac\n"; string(data) != want {
+		t.Errorf("release playlist = %q, want %q", data, want)
+	}
+	data, _ = os.ReadFile(filepath.Join(root, "Top 10.m3u8"))
+	if want := "#EXTM3U\n#BEATPORTDL-CONTEXT:playlist\nmau5trap/Strobe/01. deadmau5 - Strobe (Original Mix).flac\n"; string(data) != want {
+		t.Errorf("playlist in root = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/vbauerster/mpb/v8/decor"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// runReport counts what happened to the tracks of one batch of URLs and
+// collects the URLs that failed, so that they can be retried.
+type runReport struct {
+	mutex sync.Mutex
+	start time.Time
+
+	downloaded     int
+	skippedExists  int
+	skippedHistory int
+	updated        int
+	bytes          int64
+
+	failed     map[string]struct{}
+	failedUrls []string
+}
+
+func newRunReport() *runReport {
+	return &runReport{
+		start:  time.Now(),
+		failed: make(map[string]struct{}),
+	}
+}
+
+// The methods do nothing on a nil report, outside of a batch of downloads.
+
+func (r *runReport) addDownloaded(size int64) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.downloaded++
+	r.bytes += size
+}
+
+func (r *runReport) addUpdated() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.updated++
+}
+
+func (r *runReport) addSkipped(reason string) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	switch reason {
+	case skipReasonHistory:
+		r.skippedHistory++
+	default:
+		r.skippedExists++
+	}
+}
+
+// addFailed records a failed URL once, in the order of the failures.
+func (r *runReport) addFailed(url string) {
+	if r == nil || url == "" {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, found := r.failed[url]; found {
+		return
+	}
+	r.failed[url] = struct{}{}
+	r.failedUrls = append(r.failedUrls, url)
+}
+
+// summary returns the report as a table.
+func (r *runReport) summary() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Downloaded\t%d\n", r.downloaded)
+	fmt.Fprintf(w, "Skipped (exists)\t%d\n", r.skippedExists)
+	fmt.Fprintf(w, "Skipped (history)\t%d\n", r.skippedHistory)
+	fmt.Fprintf(w, "Updated\t%d\n", r.updated)
+	fmt.Fprintf(w, "Failed URLs\t%d\n", len(r.failedUrls))
+	fmt.Fprintf(w, "Size\t% .1f\n", decor.SizeB1000(r.bytes))
+	fmt.Fprintf(w, "Elapsed\t%s\n", time.Since(r.start).Round(time.Second))
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeFailed writes the failed URLs to path, one per line, so that the file
+// can be passed back as input.
+func (r *runReport) writeFailed(path string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	for _, url := range r.failedUrls {
+		fmt.Fprintln(w, url)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// finishReport prints the summary of the current batch and writes its failed
+// URLs to a failed-<timestamp>.txt file.
+func (app *application) finishReport() {
+	report := app.report
+	app.report = nil
+	if report == nil {
+		return
+	}
+
+	app.LogInfo("\n" + report.summary())
+
+	if len(report.failedUrls) == 0 {
+		return
+	}
+	failedPath, _, err := FindFailedFile(report.start)
+	if err != nil {
+		app.LogError("write failed urls", err)
+		return
+	}
+	if err := report.writeFailed(failedPath); err != nil {
+		app.LogError("write failed urls", err)
+		return
+	}
+	app.LogInfo("Failed URLs written to " + failedPath)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unspok3n/beatportdl/config"
+)
+
+func TestRunReport(t *testing.T) {
+	app := &application{
+		config: &config.AppConfig{RetryAttempts: 3},
+		logger: slog.New(newConsoleHandler(func() io.Writer { return io.Discard }, slog.LevelInfo)),
+		report: newRunReport(),
+	}
+
+	app.report.addDownloaded(1500000)
+	app.report.addDownloaded(500000)
+	app.report.addSkipped(skipReasonHistory)
+	app.report.addSkipped(skipReasonFileExists)
+	app.report.addSkipped(skipReasonSynced)
+	app.report.addUpdated()
+
+	release := "https://www.beatport.com/release/x/1"
+	track := "https://www.beatport.com/track/y/2"
+	app.retryLogWrapper(track, 1, time.Second, errors.New("bad status: 503"))
+	app.errorLogWrapper(release, "fetch release", errors.New("not found"))
+	app.errorLogWrapper(track, "handle track", errors.New("save track"))
+	app.postProcessLogWrapper(release, "write m3u8 playlist", errors.New("permission denied"))
+	app.postProcessLogWrapper("/music/rekordbox.xml", "export rekordbox collection", errors.New("permission denied"))
+
+	summary := app.report.summary()
+	for _, line := range []string{
+		"Downloaded         2",
+		"Skipped (exists)   2",
+		"Skipped (history)  1",
+		"Updated            1",
+		"Failed URLs        2",
+		"Size               2.0 MB",
+	} {
+		if !strings.Contains(summary, line) {
+			t.Errorf("summary does not contain %q:\n%s", line, summary)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "failed.txt")
+	if err := app.report.writeFailed(path); err != nil {
+		t.Fatalf("writeFailed() failed: %v", err)
+	}
+	app.parseTextFile(path)
+	if expected := []string{release, track}; !slices.Equal(app.urls, expected) {
+		t.Errorf("urls read back = %v, want %v", app.urls, expected)
+	}
+
+	var report *runReport
+	report.addFailed(release)
+	report.addDownloaded(1)
+}
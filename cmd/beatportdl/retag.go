@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/taglib"
+)
+
+var (
+	ErrFixTagsDisabled = errors.New("fix_tags is disabled")
+)
+
+// retagChange is a mapped field whose tag value differs from the current one.
+type retagChange struct {
+	Field    string
+	Property string
+	Old      string
+	New      string
+}
+
+// retag rewrites the tags of existing downloads with the current tag mappings
+// and cover settings. The track is identified by the tag mapped to track_id,
+// or by the {id} placeholder of the track file template. Only the tags are
+// rewritten, the audio data is left as is.
+func (app *application) retag(args []string) error {
+	fs := flag.NewFlagSet("retag", flag.ExitOnError)
+	dryRun := fs.Bool("n", false, "Show the changed fields without writing them")
+	fs.Parse(args)
+
+	if !app.config.FixTags {
+		return ErrFixTagsDisabled
+	}
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{app.config.DownloadsDirectory}
+	}
+
+	var files []string
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != dir && d.Name() == syncTrashDirectory {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if slices.Contains(scanExtensions, strings.ToLower(filepath.Ext(path))) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	idPattern := trackIDPattern(app.config.TrackFileTemplate, app.config.WhitespaceCharacter)
+	releases := newReleaseCache()
+	defer releases.removeCovers()
+
+	var changed, failed atomic.Int64
+	wg := sync.WaitGroup{}
+	for _, path := range files {
+		app.downloadWorker(&wg, func() {
+			changes, err := app.retagFile(path, idPattern, releases, *dryRun)
+			if err != nil {
+				failed.Add(1)
+				app.errorLogWrapper(path, "retag", err)
+				return
+			}
+			if len(changes) == 0 {
+				return
+			}
+			changed.Add(1)
+			var b strings.Builder
+			b.WriteString(path)
+			for _, c := range changes {
+				fmt.Fprintf(&b, "\n  %s (%s): %q -> %q", c.Field, c.Property, c.Old, c.New)
+			}
+			app.LogInfo(b.String())
+		})
+	}
+	wg.Wait()
+
+	summary := fmt.Sprintf("%d of %d files changed", changed.Load(), len(files))
+	if *dryRun {
+		summary += " (dry run)"
+	}
+	if n := failed.Load(); n > 0 {
+		summary += fmt.Sprintf(", %d failed", n)
+	}
+	app.LogInfo(summary)
+	return nil
+}
+
+// retagFile fetches the track of a file and returns the changes of its mapped
+// fields, which are written unless dryRun is set. Tags that aren't mapped are
+// left untouched.
+func (app *application) retagFile(path string, idPattern *regexp.Regexp, releases *releaseCache, dryRun bool) ([]retagChange, error) {
+	fileExt := strings.ToLower(filepath.Ext(path))
+	mappings, id3v2 := app.tagMappings(fileExt)
+
+	file, err := taglib.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]string, len(mappings))
+	for field, property := range mappings {
+		current[field] = readTag(file, property, id3v2)
+	}
+	file.Close()
+
+	id, err := fileTrackID(path, current["track_id"], idPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	track, err := app.bp.GetTrackContext(app.ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("fetch track: %w", err)
+	}
+	release, err := releases.get(app, track.Release.ID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch track release: %w", err)
+	}
+	track.Release = *release.release
+
+	values := app.tagValues(track)
+	var changes []retagChange
+	for field, property := range mappings {
+		if current[field] != values[field] {
+			changes = append(changes, retagChange{
+				Field:    field,
+				Property: property,
+				Old:      current[field],
+				New:      values[field],
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	if dryRun {
+		return changes, nil
+	}
+
+	var cover string
+	if app.config.CoverSize != config.DefaultCoverSize || fileExt != ".flac" {
+		cover, err = release.cover()
+		if err != nil {
+			return nil, fmt.Errorf("download cover: %w", err)
+		}
+	}
+	if err := app.writeTags(path, track, cover, true); err != nil {
+		return nil, fmt.Errorf("tag track: %w", err)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/collection"
+	"unspok3n/beatportdl/internal/taglib"
+)
+
+var (
+	ErrNoCollectionExport = errors.New("neither rekordbox_xml nor traktor_nml is configured")
+)
+
+var scanExtensions = []string{".flac", ".m4a", ".mp3", ".aiff", ".wav"}
+
+// scan exports the tracks of existing download directories to the configured
+// DJ software collections, reading the metadata back from the tags that were
+// written according to the tag mappings. Playlists are taken from .m3u8 files.
+func (app *application) scan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	fs.Parse(args)
+
+	if app.session == nil {
+		return ErrNoCollectionExport
+	}
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{app.config.DownloadsDirectory}
+	}
+
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != dir && d.Name() == syncTrashDirectory {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			switch {
+			case ext == playlistFileExtension:
+				playlist, err := readPlaylistFile(path)
+				if err != nil {
+					app.errorLogWrapper(path, "read playlist", err)
+					return nil
+				}
+				app.session.AddPlaylist(*playlist)
+			case slices.Contains(scanExtensions, ext):
+				track, err := app.scanTrack(path)
+				if err != nil {
+					app.errorLogWrapper(path, "read tags", err)
+					return nil
+				}
+				app.session.AddTrack(*track)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	app.exportCollections()
+	return nil
+}
+
+func (app *application) scanTrack(path string) (*collection.Track, error) {
+	location, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	track := &collection.Track{Location: location}
+	if info, err := os.Stat(location); err == nil {
+		track.Size = info.Size()
+	}
+
+	file, err := taglib.Read(location)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	track.Length = time.Duration(file.Length()) * time.Second
+
+	mappings, id3v2 := app.tagMappings(filepath.Ext(location))
+	// value returns the tag of the first mapped field that is set, so the
+	// fields are listed from the most to the least complete one
+	value := func(fields ...string) string {
+		for _, field := range fields {
+			property, ok := mappings[field]
+			if !ok {
+				continue
+			}
+			if v := readTag(file, property, id3v2); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	track.Title = value("track_name")
+	track.Artists = value("track_artists", "track_artists_limited")
+	track.Remixers = value("track_remixers", "track_remixers_limited")
+	track.Album = value("release_name")
+	track.Genre = value("track_genre", "track_subgenre_or_genre", "track_genre_with_subgenre", "track_subgenre")
+	track.Label = value("release_label")
+	track.ISRC = value("track_isrc")
+	track.ReleaseDate = value("release_date", "release_year")
+
+	number, _, _ := strings.Cut(value("track_number", "track_number_with_padding", "track_number_with_total"), "/")
+	track.Number, _ = strconv.Atoi(number)
+
+	if bpm, err := strconv.ParseFloat(value("track_bpm"), 64); err == nil {
+		track.BPM = int(bpm + 0.5)
+	}
+
+	if key := value("track_key"); key != "" {
+		track.Key = key
+		if musicalKey, err := beatport.ParseKey(key, app.config.KeySystem); err == nil {
+			track.MusicalKey = musicalKey
+		}
+	}
+
+	if track.Title == "" {
+		track.Title = strings.TrimSuffix(filepath.Base(location), filepath.Ext(location))
+	}
+
+	return track, nil
+}
+
+// readPlaylistFile reads an M3U playlist written by writePlaylistFile.
+func readPlaylistFile(path string) (*collection.Playlist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	playlist := &collection.Playlist{
+		Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		location := filepath.FromSlash(line)
+		if !filepath.IsAbs(location) {
+			location = filepath.Join(dir, location)
+		}
+		playlist.Locations = append(playlist.Locations, location)
+	}
+	return playlist, scanner.Err()
+}
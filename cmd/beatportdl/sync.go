@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+const (
+	syncManifestFilename = ".beatportdl-sync.json"
+	syncTrashDirectory   = ".trash"
+)
+
+var (
+	ErrSyncRequiresContext = errors.New("sync requires sort_by_context to be enabled")
+	ErrUnsupportedSyncLink = errors.New("only playlists and charts can be synced")
+	ErrNoSyncUrls          = errors.New("no playlist or chart urls provided")
+)
+
+// collectionTrack is a track of a playlist, chart or release together with
+// its position in that collection and the location of the downloaded file.
+type collectionTrack struct {
+	Position int
+	Track    beatport.Track
+	Location string
+}
+
+// collectionTracks gathers the tracks saved by concurrent download workers.
+type collectionTracks struct {
+	mutex sync.Mutex
+	items []collectionTrack
+}
+
+func (c *collectionTracks) add(position int, track beatport.Track, location string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = append(c.items, collectionTrack{
+		Position: position,
+		Track:    track,
+		Location: location,
+	})
+}
+
+func (c *collectionTracks) sorted() []collectionTrack {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	items := make([]collectionTrack, len(c.items))
+	copy(items, c.items)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Position < items[j].Position
+	})
+	return items
+}
+
+type syncManifest struct {
+	URL     string              `json:"url"`
+	Updated time.Time           `json:"updated"`
+	Tracks  []syncManifestTrack `json:"tracks"`
+}
+
+type syncManifestTrack struct {
+	Position int    `json:"position"`
+	TrackID  int64  `json:"track_id"`
+	Path     string `json:"path"`
+}
+
+// playlistSync mirrors a remote playlist or chart into its directory. Tracks
+// listed in the manifest whose files are still present are kept as they are,
+// everything else is downloaded, and tracks that disappeared from the remote
+// list are removed once all downloads have finished.
+type playlistSync struct {
+	url      string
+	dir      string
+	existing map[int64]syncManifestTrack
+	remote   map[int64]struct{}
+}
+
+func (app *application) startPlaylistSync(link *beatport.Link, dir string) (*playlistSync, error) {
+	if !app.config.SortByContext {
+		return nil, ErrSyncRequiresContext
+	}
+
+	s := &playlistSync{
+		url:      link.Original,
+		dir:      dir,
+		existing: make(map[int64]syncManifestTrack),
+		remote:   make(map[int64]struct{}),
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, syncManifestFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	var manifest syncManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	for _, track := range manifest.Tracks {
+		s.existing[track.TrackID] = track
+	}
+
+	return s, nil
+}
+
+// keep registers a remote track and reports whether its local copy is already
+// in place, adding it to tracks in that case. A nil playlistSync keeps nothing.
+func (s *playlistSync) keep(position int, track beatport.Track, tracks *collectionTracks) bool {
+	if s == nil {
+		return false
+	}
+	s.remote[track.ID] = struct{}{}
+
+	existing, found := s.existing[track.ID]
+	if !found {
+		return false
+	}
+	location := filepath.Join(s.dir, existing.Path)
+	if !fileExists(location) {
+		return false
+	}
+	tracks.add(position, track, location)
+	return true
+}
+
+func (app *application) finishPlaylistSync(s *playlistSync, tracks *collectionTracks) error {
+	if s == nil || app.ctx.Err() != nil {
+		return nil
+	}
+
+	for id, track := range s.existing {
+		if _, found := s.remote[id]; found {
+			continue
+		}
+		if err := app.removeSyncedTrack(s.dir, track.Path); err != nil {
+			return fmt.Errorf("remove track %d: %w", id, err)
+		}
+	}
+
+	manifest := syncManifest{
+		URL:     s.url,
+		Updated: time.Now().UTC(),
+		Tracks:  []syncManifestTrack{},
+	}
+	for _, item := range tracks.sorted() {
+		relPath, err := filepath.Rel(s.dir, item.Location)
+		if err != nil {
+			return err
+		}
+		manifest.Tracks = append(manifest.Tracks, syncManifestTrack{
+			Position: item.Position,
+			TrackID:  item.Track.ID,
+			Path:     filepath.ToSlash(relPath),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", " ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, syncManifestFilename), data, 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+func (app *application) removeSyncedTrack(dir string, relPath string) error {
+	location := filepath.Join(dir, filepath.FromSlash(relPath))
+	if !fileExists(location) {
+		return nil
+	}
+
+	switch app.config.SyncRemovedTracks {
+	case "delete":
+		if err := os.Remove(location); err != nil {
+			return err
+		}
+		app.infoLogWrapper(relPath, "deleted, no longer in the playlist")
+	default:
+		trashPath := filepath.Join(dir, syncTrashDirectory, filepath.FromSlash(relPath))
+		if err := CreateDirectory(filepath.Dir(trashPath)); err != nil {
+			return err
+		}
+		if err := os.Rename(location, trashPath); err != nil {
+			return err
+		}
+		app.infoLogWrapper(relPath, "moved to trash, no longer in the playlist")
+	}
+
+	if parent := filepath.Dir(location); parent != dir {
+		os.Remove(parent)
+	}
+	return nil
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/vbauerster/mpb/v8"
 	"github.com/vbauerster/mpb/v8/decor"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path"
@@ -15,28 +17,101 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
+	"unspok3n/beatportdl/internal/retry"
 )
 
-func (app *application) downloadFile(url string, destination string, pbPrefix string) error {
-	out, err := os.Create(destination)
+const (
+	partFileSuffix = ".part"
+)
+
+var (
+	ErrIncompleteDownload = errors.New("incomplete download")
+)
+
+// downloadFile streams url into a ".part" sidecar next to destination and
+// renames it into place once the size matches the expected length. A leftover
+// sidecar from an earlier attempt is resumed with a Range request when the
+// server supports it, otherwise the download starts over. Failed attempts are
+// retried according to the retry policy and resume from the sidecar as well.
+// When ctx is cancelled the transfer is aborted and the sidecar is removed.
+func (app *application) downloadFile(ctx context.Context, url string, destination string, progress *downloadProgress) error {
+	err := app.retry.Do(ctx, url, func() error {
+		return app.downloadFileAttempt(ctx, url, destination, progress)
+	})
+	if err != nil && ctx.Err() != nil {
+		os.Remove(destination + partFileSuffix)
+	}
+	return err
+}
+
+func (app *application) downloadFileAttempt(ctx context.Context, url string, destination string, progress *downloadProgress) (err error) {
+	partPath := destination + partFileSuffix
+
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("create file: %w", err)
+		return retry.Permanent(fmt.Errorf("create request: %w", err))
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
-	defer out.Close()
 
-	resp, err := http.Get(url)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("download file: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+	var total int64
+	flags := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset = 0
+		total = resp.ContentLength
+		flags |= os.O_TRUNC
+	case http.StatusPartialContent:
+		start, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if start != offset {
+			return fmt.Errorf("unexpected range start: %d, expected %d", start, offset)
+		}
+		total = size
+		flags |= os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		_, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err == nil && size == offset {
+			return os.Rename(partPath, destination)
+		}
+		if err := os.Remove(partPath); err != nil {
+			return retry.Permanent(fmt.Errorf("remove stale part file: %w", err))
+		}
+		resp.Body.Close()
+		return app.downloadFileAttempt(ctx, url, destination, progress)
+	default:
+		return retry.NewStatusError(resp, fmt.Errorf("bad status: %s", resp.Status))
 	}
 
-	if pbPrefix != "" {
-		contentLength, _ := strconv.Atoi(resp.Header.Get("Content-Length"))
-		bar := app.pbp.AddBar(int64(contentLength), ProgressBarOptions(pbPrefix)...)
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("create file: %w", err))
+	}
+	defer out.Close()
+
+	if progress != nil {
+		bar := app.addProgressBar(progress, total, "bytes")
+		bar.SetCurrent(offset)
+		defer func() {
+			if err != nil {
+				bar.Abort(true)
+			}
+		}()
 
 		proxyReader := bar.ProxyReader(resp.Body)
 		defer proxyReader.Close()
@@ -52,7 +127,83 @@ func (app *application) downloadFile(url string, destination string, pbPrefix st
 		}
 	}
 
-	return nil
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if total >= 0 {
+		info, err := os.Stat(partPath)
+		if err != nil {
+			return err
+		}
+		if info.Size() != total {
+			return fmt.Errorf("%w: %d of %d bytes", ErrIncompleteDownload, info.Size(), total)
+		}
+	}
+
+	return os.Rename(partPath, destination)
+}
+
+// httpGet fetches a small resource such as an HLS playlist, key or segment
+// into memory, retrying transient failures according to the retry policy.
+func (app *application) httpGet(ctx context.Context, url string) ([]byte, error) {
+	var data []byte
+	err := app.retry.Do(ctx, url, func() error {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return retry.NewStatusError(resp, fmt.Errorf("request failed with status code: %d", resp.StatusCode))
+		}
+		data, err = io.ReadAll(resp.Body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseContentRange parses a "bytes start-end/size" or "bytes */size" header.
+// The size is -1 when the server reports it as unknown.
+func parseContentRange(header string) (start int64, size int64, err error) {
+	invalid := fmt.Errorf("invalid content range: %q", header)
+
+	spec, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, invalid
+	}
+	rangeSpec, sizeSpec, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, invalid
+	}
+
+	size = -1
+	if sizeSpec != "*" {
+		size, err = strconv.ParseInt(sizeSpec, 10, 64)
+		if err != nil {
+			return 0, 0, invalid
+		}
+	}
+
+	if rangeSpec != "*" {
+		startSpec, _, found := strings.Cut(rangeSpec, "-")
+		if !found {
+			return 0, 0, invalid
+		}
+		start, err = strconv.ParseInt(startSpec, 10, 64)
+		if err != nil {
+			return 0, 0, invalid
+		}
+	}
+
+	return start, size, nil
 }
 
 func toMetaFunc(c *color.Color) func(string) string {
@@ -106,24 +257,40 @@ func GetLine() string {
 	return input
 }
 
+// Pause waits for enter before exiting, so that the error stays visible when
+// started from a file manager. The prompt goes to stderr to keep stdout clean
+// in JSON output mode.
 func Pause() {
-	fmt.Println("\nPress enter to exit")
+	fmt.Fprintln(os.Stderr, "\nPress enter to exit")
 	fmt.Scanln()
 	os.Exit(1)
 }
 
 func (app *application) LogError(caller string, err error) {
-	message := fmt.Sprintf("%s: %s\n", caller, err.Error())
-	fmt.Fprint(app.logWriter, message)
+	app.logError("", caller, err)
+}
 
-	if app.logFile != nil {
-		app.logFile.WriteString(message)
+// logError logs an error of a step for url, which may be empty, and emits it
+// as an event in JSON output mode.
+func (app *application) logError(url, step string, err error) {
+	app.emit(event{Event: eventError, URL: url, Step: step, Error: err.Error()})
+	if url != "" {
+		app.log().Error(step, "url", url, "error", err)
+	} else {
+		app.log().Error(step, "error", err)
 	}
 }
 
 func (app *application) LogInfo(info string) {
-	message := fmt.Sprintf("%s\n", info)
-	fmt.Fprint(app.logWriter, message)
+	app.log().Info(info)
+}
+
+// log returns the logger, which is only missing before setupLogging.
+func (app *application) log() *slog.Logger {
+	if app.logger == nil {
+		return slog.Default()
+	}
+	return app.logger
 }
 
 func (app *application) FatalError(caller string, err error) {
@@ -152,6 +319,14 @@ func WorkingDirFilePath(fileName string) (string, error) {
 }
 
 func FindConfigFile() (string, bool, error) {
+	return findFile(configFilename, configDirs())
+}
+
+func FindWatchlistFile() (string, bool, error) {
+	return findFile(watchlistFilename, configDirs())
+}
+
+func configDirs() []string {
 	var additionalDirs []string
 
 	if runtime.GOOS == "linux" {
@@ -164,10 +339,28 @@ func FindConfigFile() (string, bool, error) {
 		additionalDirs = append(additionalDirs, additionalDir)
 	}
 
-	return findFile(configFilename, additionalDirs)
+	return additionalDirs
+}
+
+// FindCacheFile finds the credentials cache, a separate one for every
+// config profile.
+func FindCacheFile(profile string) (string, bool, error) {
+	filename := cacheFilename
+	if profile != "" {
+		filename = strings.TrimSuffix(cacheFilename, ".json") + "-" + profile + ".json"
+	}
+	return findFile(filename, stateDirs())
 }
 
-func FindCacheFile() (string, bool, error) {
+func FindHistoryFile() (string, bool, error) {
+	return findFile(historyFilename, stateDirs())
+}
+
+func FindWatchStateFile() (string, bool, error) {
+	return findFile(watchStateFilename, stateDirs())
+}
+
+func stateDirs() []string {
 	var additionalDirs []string
 
 	if runtime.GOOS == "linux" {
@@ -180,7 +373,7 @@ func FindCacheFile() (string, bool, error) {
 		additionalDirs = append(additionalDirs, additionalDir)
 	}
 
-	return findFile(cacheFilename, additionalDirs)
+	return additionalDirs
 }
 
 func FindErrorLogFile() (string, bool, error) {
@@ -188,6 +381,13 @@ func FindErrorLogFile() (string, bool, error) {
 	return findFile(errorFilename, additionalDirs)
 }
 
+// FindFailedFile returns the path of the failed URLs file of a run started at
+// t, next to the error log.
+func FindFailedFile(t time.Time) (string, bool, error) {
+	var additionalDirs []string
+	return findFile(fmt.Sprintf(failedFilenameFormat, t.Format("20060102-150405")), additionalDirs)
+}
+
 func findFile(fileName string, additionalDirs []string) (string, bool, error) {
 	configFilePaths := []string{}
 
@@ -220,6 +420,11 @@ func findFile(fileName string, additionalDirs []string) (string, bool, error) {
 	return defaultFilePath, false, nil
 }
 
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
 func CreateDirectory(directory string) error {
 	if _, err := os.Stat(directory); os.IsNotExist(err) {
 		if err := os.MkdirAll(directory, 0760); err != nil {
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestFindConfigFile(t *testing.T) {
@@ -44,7 +50,7 @@ func TestFindCacheFile(t *testing.T) {
 	xdgStateHome := "/tmp/foo/bar"
 
 	t.Run("Use default XDG_STATE_HOME without env being set", func(t *testing.T) {
-		cacheFilePath, _, gotErr := FindCacheFile()
+		cacheFilePath, _, gotErr := FindCacheFile("")
 		if gotErr != nil {
 			t.Errorf("FindCacheFile() failed: %v", gotErr)
 			return
@@ -60,7 +66,7 @@ func TestFindCacheFile(t *testing.T) {
 	t.Run("Use XDG_STATE_HOME with env being set", func(t *testing.T) {
 		os.Setenv("XDG_STATE_HOME", xdgStateHome)
 
-		cacheFilePath, _, gotErr := FindCacheFile()
+		cacheFilePath, _, gotErr := FindCacheFile("")
 		if gotErr != nil {
 			t.Errorf("FindCacheFile() failed: %v", gotErr)
 			return
@@ -72,4 +78,79 @@ func TestFindCacheFile(t *testing.T) {
 			t.Errorf("Paths do not match, %s != %s", expectedPath, cacheFilePath)
 		}
 	})
+	t.Run("Use a separate cache for a profile", func(t *testing.T) {
+		os.Setenv("XDG_STATE_HOME", xdgStateHome)
+
+		cacheFilePath, _, gotErr := FindCacheFile("promo")
+		if gotErr != nil {
+			t.Errorf("FindCacheFile() failed: %v", gotErr)
+			return
+		}
+
+		expectedPath := path.Join(xdgStateHome, "beatportdl", "beatportdl-credentials-promo.json")
+
+		if expectedPath != cacheFilePath {
+			t.Errorf("Paths do not match, %s != %s", expectedPath, cacheFilePath)
+		}
+	})
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantStart int64
+		wantSize  int64
+		wantErr   bool
+	}{
+		{"bytes 100-199/200", 100, 200, false},
+		{"bytes */200", 0, 200, false},
+		{"bytes 0-99/*", 0, -1, false},
+		{"items 0-99/200", 0, 0, true},
+		{"bytes 100-199", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		start, size, err := parseContentRange(tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseContentRange(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			continue
+		}
+		if start != tt.wantStart || size != tt.wantSize {
+			t.Errorf("parseContentRange(%q) = %d, %d, want %d, %d", tt.header, start, size, tt.wantStart, tt.wantSize)
+		}
+	}
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	content := bytes.Repeat([]byte("beatportdl"), 1000)
+	var rangeHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader = r.Header.Get("Range")
+		http.ServeContent(w, r, "track.flac", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	destination := filepath.Join(t.TempDir(), "track.flac")
+	if err := os.WriteFile(destination+partFileSuffix, content[:4000], 0644); err != nil {
+		t.Fatalf("write part file: %v", err)
+	}
+
+	app := &application{}
+	if err := app.downloadFile(context.Background(), server.URL, destination, nil); err != nil {
+		t.Fatalf("downloadFile() failed: %v", err)
+	}
+
+	if rangeHeader != "bytes=4000-" {
+		t.Errorf("Range header = %q, want %q", rangeHeader, "bytes=4000-")
+	}
+	got, err := os.ReadFile(destination)
+	if err != nil {
+		t.Fatalf("read destination: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("downloaded content mismatch: got %d bytes, want %d", len(got), len(content))
+	}
+	if _, err := os.Stat(destination + partFileSuffix); !os.IsNotExist(err) {
+		t.Errorf("part file was not renamed into place")
+	}
 }
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+var (
+	ErrUnsupportedWatchLink = errors.New("only label and artist urls can be watched")
+)
+
+// watchMark is the high-water mark of a watched label or artist: the latest
+// release date seen so far and the IDs already queued on that date. Pending
+// are the URLs past the mark whose download failed, they are queued again on
+// every check until they succeed.
+type watchMark struct {
+	Date        string    `json:"date"`
+	IDs         []int64   `json:"ids"`
+	Pending     []string  `json:"pending,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+}
+
+func (m *watchMark) isNew(date string, id int64) bool {
+	if date != m.Date {
+		return date > m.Date
+	}
+	return !slices.Contains(m.IDs, id)
+}
+
+func (m *watchMark) advance(date string, id int64) {
+	switch {
+	case date > m.Date:
+		m.Date = date
+		m.IDs = []int64{id}
+	case date == m.Date && !slices.Contains(m.IDs, id):
+		m.IDs = append(m.IDs, id)
+	}
+}
+
+// queue returns the pending URLs followed by the new ones.
+func (m *watchMark) queue(urls []string) []string {
+	return append(slices.Clone(m.Pending), urls...)
+}
+
+// keepFailed replaces the pending URLs with the ones that failed in report.
+func (m *watchMark) keepFailed(report *runReport) {
+	m.Pending = nil
+	if report != nil {
+		m.Pending = slices.Clone(report.failedUrls)
+	}
+}
+
+type watchState map[string]*watchMark
+
+func loadWatchState(path string) (watchState, error) {
+	state := make(watchState)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read watch state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("decode watch state: %w", err)
+	}
+	return state, nil
+}
+
+func (s watchState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", " ")
+	if err != nil {
+		return fmt.Errorf("encode watch state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("could not create folder for watch state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("write watch state: %w", err)
+	}
+	return nil
+}
+
+func readWatchlist(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open watchlist: %w", err)
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, scanner.Err()
+}
+
+// watch polls every label and artist in the watchlist and queues the releases
+// (or artist tracks) published after the stored high-water mark.
+func (app *application) watch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	daemon := fs.Bool("daemon", false, "Keep running and check the watchlist periodically")
+	interval := fs.Duration("interval", 6*time.Hour, "Time between checks in daemon mode")
+	seed := fs.Bool("seed", false, "Record the current releases as seen without downloading them")
+	fs.Parse(args)
+
+	watchlistPath, _, err := FindWatchlistFile()
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		watchlistPath = fs.Arg(0)
+	}
+
+	statePath, _, err := FindWatchStateFile()
+	if err != nil {
+		return err
+	}
+	state, err := loadWatchState(statePath)
+	if err != nil {
+		return err
+	}
+
+	for {
+		entries, err := readWatchlist(watchlistPath)
+		if err != nil {
+			return err
+		}
+
+		queued := make(map[string][]string)
+		for _, entry := range entries {
+			mark, exists := state[entry]
+			if !exists {
+				mark = &watchMark{}
+				state[entry] = mark
+			}
+			urls, err := app.checkWatchEntry(entry, mark)
+			if err != nil {
+				app.errorLogWrapper(entry, "check watched url", err)
+			} else if len(urls) > 0 {
+				app.infoLogWrapper(entry, fmt.Sprintf("%d new", len(urls)))
+			}
+			if len(mark.Pending) > 0 {
+				app.infoLogWrapper(entry, fmt.Sprintf("%d failed earlier", len(mark.Pending)))
+			}
+			if !*seed {
+				queued[entry] = mark.queue(urls)
+			}
+		}
+
+		// Every entry is downloaded as its own batch, so that its failed
+		// downloads can be kept as pending
+		for _, entry := range entries {
+			if len(queued[entry]) == 0 || app.ctx.Err() != nil {
+				continue
+			}
+			app.urls = queued[entry]
+			state[entry].keepFailed(app.downloadUrls())
+		}
+		app.urls = []string{}
+
+		if app.ctx.Err() != nil {
+			return nil
+		}
+		if err := state.save(statePath); err != nil {
+			return err
+		}
+		if !*daemon {
+			return nil
+		}
+
+		app.LogInfo(fmt.Sprintf("Next check at %s", time.Now().Add(*interval).Format(time.DateTime)))
+		select {
+		case <-app.ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// checkWatchEntry returns the store URLs of everything newer than mark and
+// advances mark past them.
+func (app *application) checkWatchEntry(entry string, mark *watchMark) ([]string, error) {
+	link, err := app.bp.ParseUrl(entry)
+	if err != nil {
+		return nil, err
+	}
+	params := watchParams(link.Params, mark.Date)
+
+	var urls []string
+	next := *mark
+	next.IDs = slices.Clone(mark.IDs)
+
+	switch link.Type {
+	case beatport.LabelLink:
+		err = ForPaginated[beatport.Release](app.ctx, link.ID, params, app.bp.GetLabelReleasesContext, func(release beatport.Release, i int) error {
+			if mark.isNew(release.Date, release.ID) {
+				urls = append(urls, release.StoreUrl())
+				next.advance(release.Date, release.ID)
+			}
+			return nil
+		})
+	case beatport.ArtistLink:
+		err = ForPaginated[beatport.Track](app.ctx, link.ID, params, app.bp.GetArtistTracksContext, func(track beatport.Track, i int) error {
+			date := track.Release.Date
+			if date == "" {
+				date = track.PublishDate
+			}
+			if mark.isNew(date, track.ID) {
+				urls = append(urls, track.StoreUrl())
+				next.advance(date, track.ID)
+			}
+			return nil
+		})
+	default:
+		return nil, ErrUnsupportedWatchLink
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	next.LastChecked = time.Now().UTC()
+	*mark = next
+	return urls, nil
+}
+
+// watchParams narrows the filters of a watched URL down to releases from the
+// high-water mark onwards, keeping a later start date if the URL has one.
+func watchParams(params string, since string) string {
+	if since == "" {
+		return params
+	}
+
+	var kept []string
+	from, to := since, ""
+	for _, param := range strings.Split(params, "&") {
+		if value, found := strings.CutPrefix(param, "new_release_date="); found {
+			userFrom, userTo, _ := strings.Cut(value, ":")
+			if userFrom > from {
+				from = userFrom
+			}
+			to = userTo
+			continue
+		}
+		if param != "" {
+			kept = append(kept, param)
+		}
+	}
+	kept = append(kept, fmt.Sprintf("new_release_date=%s:%s", from, to))
+	return strings.Join(kept, "&")
+}
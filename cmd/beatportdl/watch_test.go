@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWatchParams(t *testing.T) {
+	tests := []struct {
+		params string
+		since  string
+		want   string
+	}{
+		{"genre_name=Techno", "", "genre_name=Techno"},
+		{"", "2024-05-01", "new_release_date=2024-05-01:"},
+		{"genre_name=Techno&new_release_date=2020-01-01:2024-12-31", "2024-05-01", "genre_name=Techno&new_release_date=2024-05-01:2024-12-31"},
+		{"new_release_date=2025-01-01:", "2024-05-01", "new_release_date=2025-01-01:"},
+	}
+
+	for _, tt := range tests {
+		if got := watchParams(tt.params, tt.since); got != tt.want {
+			t.Errorf("watchParams(%q, %q) = %q, want %q", tt.params, tt.since, got, tt.want)
+		}
+	}
+}
+
+func TestWatchMark(t *testing.T) {
+	mark := &watchMark{Date: "2024-05-01", IDs: []int64{10}}
+
+	if mark.isNew("2024-04-30", 5) {
+		t.Errorf("older release reported as new")
+	}
+	if mark.isNew("2024-05-01", 10) {
+		t.Errorf("already seen release reported as new")
+	}
+	if !mark.isNew("2024-05-01", 11) || !mark.isNew("2024-05-02", 1) {
+		t.Errorf("new release not reported")
+	}
+
+	mark.advance("2024-05-01", 11)
+	mark.advance("2024-04-01", 3)
+	if mark.Date != "2024-05-01" || len(mark.IDs) != 2 {
+		t.Errorf("advance() on same date = %+v", mark)
+	}
+	mark.advance("2024-06-01", 12)
+	if mark.Date != "2024-06-01" || len(mark.IDs) != 1 || mark.IDs[0] != 12 {
+		t.Errorf("advance() to later date = %+v", mark)
+	}
+}
+
+func TestWatchPending(t *testing.T) {
+	release := "https://www.beatport.com/release/x/1"
+	track := "https://www.beatport.com/track/y/2"
+	mark := &watchMark{Date: "2024-05-01", Pending: []string{track}}
+
+	if got, want := mark.queue([]string{release}), []string{track, release}; !slices.Equal(got, want) {
+		t.Errorf("queue() = %v, want %v", got, want)
+	}
+
+	report := newRunReport()
+	report.addFailed(release)
+	mark.keepFailed(report)
+	if want := []string{release}; !slices.Equal(mark.Pending, want) {
+		t.Errorf("pending after failed download = %v, want %v", mark.Pending, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "watch.json")
+	if err := (watchState{"https://www.beatport.com/label/z/3": mark}).save(path); err != nil {
+		t.Fatalf("save() failed: %v", err)
+	}
+	state, err := loadWatchState(path)
+	if err != nil {
+		t.Fatalf("loadWatchState() failed: %v", err)
+	}
+	if got := state["https://www.beatport.com/label/z/3"]; !slices.Equal(got.Pending, mark.Pending) {
+		t.Errorf("pending read back = %v, want %v", got.Pending, mark.Pending)
+	}
+
+	mark.keepFailed(newRunReport())
+	if len(mark.Pending) != 0 {
+		t.Errorf("pending after successful download = %v", mark.Pending)
+	}
+}
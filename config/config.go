@@ -1,11 +1,11 @@
 package config
 
 import (
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path"
+	"time"
+	"unspok3n/beatportdl/internal/beatport"
 	"unspok3n/beatportdl/internal/validator"
 
 	"gopkg.in/yaml.v2"
@@ -15,17 +15,34 @@ type AppConfig struct {
 	Username      string `yaml:"username,omitempty"`
 	Password      string `yaml:"password,omitempty"`
 	Quality       string `yaml:"quality,omitempty"`
+	Format        string `yaml:"format,omitempty"`
 	WriteErrorLog bool   `yaml:"write_error_log,omitempty"`
 	ShowProgress  bool   `yaml:"show_progress,omitempty"`
+	Output        string `yaml:"output,omitempty"`
+
+	Verbose       bool   `yaml:"verbose,omitempty"`
+	LogFormat     string `yaml:"log_format,omitempty"`
+	LogMaxSize    int    `yaml:"log_max_size,omitempty"`
+	LogMaxBackups int    `yaml:"log_max_backups,omitempty"`
 
 	MaxGlobalWorkers   int `yaml:"max_global_workers,omitempty"`
 	MaxDownloadWorkers int `yaml:"max_download_workers,omitempty"`
+	MaxSegmentWorkers  int `yaml:"max_segment_workers,omitempty"`
+
+	RetryAttempts int           `yaml:"retry_attempts,omitempty"`
+	RetryDelay    time.Duration `yaml:"retry_delay,omitempty"`
+	RetryMaxDelay time.Duration `yaml:"retry_max_delay,omitempty"`
+
+	RateLimit      float64 `yaml:"rate_limit,omitempty"`
+	RateLimitBurst int     `yaml:"rate_limit_burst,omitempty"`
 
 	DownloadsDirectory      string `yaml:"downloads_directory,omitempty"`
 	SortByContext           bool   `yaml:"sort_by_context,omitempty"`
 	SortByLabel             bool   `yaml:"sort_by_label,omitempty"`
 	ForceReleaseDirectories bool   `yaml:"force_release_directories,omitempty"`
 	TrackExists             string `yaml:"track_exists,omitempty"`
+	DownloadHistory         bool   `yaml:"download_history,omitempty"`
+	SyncRemovedTracks       string `yaml:"sync_removed_tracks,omitempty"`
 	TrackNumberPadding      int    `yaml:"track_number_padding,omitempty"`
 
 	ReleaseDirectoryTemplate  string `yaml:"release_directory_template,omitempty"`
@@ -41,8 +58,12 @@ type AppConfig struct {
 
 	CoverSize string `yaml:"cover_size,omitempty"`
 	KeepCover bool   `yaml:"keep_cover,omitempty"`
+	WriteM3U8 bool   `yaml:"write_m3u8,omitempty"`
 	FixTags   bool   `yaml:"fix_tags,omitempty"`
 
+	RekordboxXML string `yaml:"rekordbox_xml,omitempty"`
+	TraktorNML   string `yaml:"traktor_nml,omitempty"`
+
 	TagMappings map[string]map[string]string `yaml:"tag_mappings,omitempty"`
 
 	Proxy string `yaml:"proxy,omitempty"`
@@ -60,26 +81,44 @@ var (
 		"update",
 	}
 
+	SupportedFormats = []string{
+		"original",
+		"mp3",
+		"aiff",
+		"wav",
+	}
+
+	SupportedSyncRemovedTracksOptions = []string{
+		"trash",
+		"delete",
+	}
+
 	SupportedKeySystems = []string{
 		"standard",
 		"standard-short",
 		"openkey",
 		"camelot",
 	}
-)
 
-func FFMPEGInstalled() bool {
-	_, err := exec.LookPath("ffmpeg")
-	return err == nil
-}
+	SupportedOutputs = []string{
+		"text",
+		"json",
+	}
 
-func Parse(filePath string) (*AppConfig, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+	SupportedLogFormats = []string{
+		"text",
+		"json",
 	}
+)
+
+// Parse reads the config file and applies the overrides on top of it, later
+// overrides taking precedence. Without a file path only the defaults and the
+// overrides are used. A non-empty profile selects an entry of the profiles
+// section, which is applied on top of the file before the overrides.
+func Parse(filePath string, profile string, overrides ...Overrides) (*AppConfig, error) {
 	config := AppConfig{
 		Quality:                   "lossless",
+		Format:                    "original",
 		CoverSize:                 DefaultCoverSize,
 		TrackFileTemplate:         "{number}. {artists} - {name} ({mix_name})",
 		ReleaseDirectoryTemplate:  "[{catalog_number}] {artists} - {name}",
@@ -91,45 +130,115 @@ func Parse(filePath string) (*AppConfig, error) {
 		ArtistsShortForm:          "VA",
 		KeySystem:                 "standard-short",
 		TrackExists:               "update",
+		SyncRemovedTracks:         "trash",
 		TrackNumberPadding:        2,
 		FixTags:                   true,
 		ShowProgress:              true,
+		Output:                    "text",
+		LogFormat:                 "text",
+		LogMaxSize:                10,
+		LogMaxBackups:             3,
 		MaxGlobalWorkers:          15,
 		MaxDownloadWorkers:        15,
+		MaxSegmentWorkers:         4,
+		RetryAttempts:             4,
+		RetryDelay:                time.Second,
+		RetryMaxDelay:             30 * time.Second,
+		RateLimit:                 10,
+		RateLimitBurst:            10,
 	}
-	decoder := yaml.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
-		return nil, fmt.Errorf("failed to decode config file: %w", err)
+	var profiles map[string]yaml.MapSlice
+	if filePath != "" {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to decode config file: %w", err)
+		}
+		var file struct {
+			Profiles map[string]yaml.MapSlice `yaml:"profiles"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to decode config profiles: %w", err)
+		}
+		profiles = file.Profiles
 	}
 
-	if config.Username == "" || config.Password == "" {
-		return nil, fmt.Errorf("username or password is not provided")
+	if profile != "" {
+		if err := config.applyProfile(profiles, profile); err != nil {
+			return nil, err
+		}
 	}
 
-	if config.Quality == "medium-hls" && !FFMPEGInstalled() {
-		return nil, errors.New("ffmpeg not found")
+	for _, o := range overrides {
+		if err := config.Apply(o); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Username == "" || config.Password == "" {
+		return nil, fmt.Errorf("username or password is not provided")
 	}
 
 	if config.TagMappings != nil {
-		if err = ValidateTagMappings(config.TagMappings); err != nil {
+		if err := ValidateTagMappings(config.TagMappings); err != nil {
 			return nil, err
 		}
 
-		if _, ok := config.TagMappings["flac"]; !ok {
-			config.TagMappings["flac"] = DefaultTagMappings["flac"]
-		}
-
-		if _, ok := config.TagMappings["m4a"]; !ok {
-			config.TagMappings["m4a"] = DefaultTagMappings["m4a"]
+		for format, mappings := range DefaultTagMappings {
+			if _, ok := config.TagMappings[format]; !ok {
+				config.TagMappings[format] = mappings
+			}
 		}
 	} else {
 		config.TagMappings = DefaultTagMappings
 	}
 
+	// sort_by_label predates nested templates, it is the same as starting the
+	// release directory template with "{label}/"
+	if config.SortByLabel {
+		config.ReleaseDirectoryTemplate = "{label}/" + config.ReleaseDirectoryTemplate
+	}
+
+	templates := []struct {
+		name     string
+		template string
+		keys     []string
+	}{
+		{"track_file_template", config.TrackFileTemplate, beatport.TrackTemplateKeys},
+		{"release_directory_template", config.ReleaseDirectoryTemplate, beatport.ReleaseTemplateKeys},
+		{"playlist_directory_template", config.PlaylistDirectoryTemplate, beatport.PlaylistTemplateKeys},
+		{"chart_directory_template", config.ChartDirectoryTemplate, beatport.ChartTemplateKeys},
+		{"label_directory_template", config.LabelDirectoryTemplate, beatport.LabelTemplateKeys},
+		{"artist_directory_template", config.ArtistDirectoryTemplate, beatport.ArtistTemplateKeys},
+	}
+	for _, t := range templates {
+		if err := beatport.ValidateTemplate(t.template, t.keys); err != nil {
+			return nil, fmt.Errorf("%s: %w", t.name, err)
+		}
+	}
+
+	if !validator.PermittedValue(config.Format, SupportedFormats...) {
+		return nil, fmt.Errorf("invalid format")
+	}
+
 	if !validator.PermittedValue(config.KeySystem, SupportedKeySystems...) {
 		return nil, fmt.Errorf("invalid key system")
 	}
 
+	if !validator.PermittedValue(config.Output, SupportedOutputs...) {
+		return nil, fmt.Errorf("invalid output")
+	}
+
+	if !validator.PermittedValue(config.LogFormat, SupportedLogFormats...) {
+		return nil, fmt.Errorf("invalid log format")
+	}
+
+	if config.LogMaxSize < 0 || config.LogMaxBackups < 0 {
+		return nil, fmt.Errorf("invalid log rotation")
+	}
+
 	if config.DownloadsDirectory == "" {
 		return nil, fmt.Errorf("no downloads directory provided")
 	}
@@ -138,10 +247,30 @@ func Parse(filePath string) (*AppConfig, error) {
 		return nil, fmt.Errorf("invalid track exists behavior")
 	}
 
+	if !validator.PermittedValue(config.SyncRemovedTracks, SupportedSyncRemovedTracksOptions...) {
+		return nil, fmt.Errorf("invalid sync removed tracks behavior")
+	}
+
 	if config.TrackNumberPadding > 10 || config.TrackNumberPadding < 0 {
 		return nil, fmt.Errorf("invalid track number padding")
 	}
 
+	if config.MaxSegmentWorkers < 1 {
+		return nil, fmt.Errorf("invalid max segment workers")
+	}
+
+	if config.RetryAttempts < 1 {
+		return nil, fmt.Errorf("invalid retry attempts")
+	}
+
+	if config.RetryDelay < 0 || config.RetryMaxDelay < 0 {
+		return nil, fmt.Errorf("invalid retry delay")
+	}
+
+	if config.RateLimit < 0 || config.RateLimitBurst < 1 {
+		return nil, fmt.Errorf("invalid rate limit")
+	}
+
 	return &config, nil
 }
 
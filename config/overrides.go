@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of the environment variables that override config
+// options, e.g. BEATPORTDL_QUALITY for quality.
+const EnvPrefix = "BEATPORTDL_"
+
+// Option is a config option that can be overridden per run. Key is the name
+// in the config file, Flag and Env are the names of the command line flag
+// and the environment variable.
+type Option struct {
+	Key  string
+	Flag string
+	Env  string
+	Type string
+
+	index int
+}
+
+// IsBool reports whether the option is a boolean, which doesn't need a value
+// on the command line.
+func (o Option) IsBool() bool {
+	return o.Type == "bool"
+}
+
+// Overrides are option values by config key, applied on top of the config
+// file. Values are parsed according to the type of the option.
+type Overrides map[string]string
+
+// FlagAliases are short flag names for the most used options.
+var FlagAliases = map[string]string{
+	"dir":      "downloads_directory",
+	"template": "track_file_template",
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Options returns every option of AppConfig in the order of the struct.
+func Options() []Option {
+	t := reflect.TypeOf(AppConfig{})
+	options := make([]Option, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		typ := field.Type.Kind().String()
+		switch {
+		case field.Type == durationType:
+			typ = "duration"
+		case field.Type.Kind() == reflect.Float64:
+			typ = "float"
+		case field.Type.Kind() == reflect.Map:
+			typ = "yaml"
+		}
+
+		options = append(options, Option{
+			Key:   key,
+			Flag:  strings.ReplaceAll(key, "_", "-"),
+			Env:   EnvPrefix + strings.ToUpper(key),
+			Type:  typ,
+			index: i,
+		})
+	}
+	return options
+}
+
+// LookupOption finds an option by its config key, flag name or flag alias.
+func LookupOption(name string) (Option, bool) {
+	if key, ok := FlagAliases[name]; ok {
+		name = key
+	}
+	for _, option := range Options() {
+		if option.Key == name || option.Flag == name {
+			return option, true
+		}
+	}
+	return Option{}, false
+}
+
+// EnvOverrides reads the BEATPORTDL_* environment variables with lookup,
+// usually os.LookupEnv.
+func EnvOverrides(lookup func(string) (string, bool)) Overrides {
+	overrides := make(Overrides)
+	for _, option := range Options() {
+		if value, ok := lookup(option.Env); ok {
+			overrides[option.Key] = value
+		}
+	}
+	return overrides
+}
+
+// Apply sets the overridden options.
+func (c *AppConfig) Apply(overrides Overrides) error {
+	v := reflect.ValueOf(c).Elem()
+	for _, option := range Options() {
+		value, ok := overrides[option.Key]
+		if !ok {
+			continue
+		}
+		if err := setOption(v.Field(option.index), option, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", option.Key, err)
+		}
+	}
+	return nil
+}
+
+func setOption(field reflect.Value, option Option, value string) error {
+	switch option.Type {
+	case "string":
+		field.SetString(value)
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case "int":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case "float":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case "duration":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case "yaml":
+		target := reflect.New(field.Type())
+		if err := yaml.Unmarshal([]byte(value), target.Interface()); err != nil {
+			return err
+		}
+		field.Set(target.Elem())
+	default:
+		return fmt.Errorf("unsupported option type %s", option.Type)
+	}
+	return nil
+}
+
+var profileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// applyProfile decodes a profile of the profiles section on top of the
+// config. Profiles may set every option but other profiles.
+func (c *AppConfig) applyProfile(profiles map[string]yaml.MapSlice, name string) error {
+	if !profileNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid profile name '%s'", name)
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("profile '%s' not found", name)
+	}
+
+	for _, item := range profile {
+		key, _ := item.Key.(string)
+		if option, ok := LookupOption(key); !ok || option.Key != key {
+			return fmt.Errorf("invalid option '%v' in profile '%s'", item.Key, name)
+		}
+	}
+
+	data, err := yaml.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("failed to decode profile '%s': %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beatportdl-config.yml")
+	data := "username: user\npassword: pass\ndownloads_directory: /music\nquality: medium\nmax_download_workers: 5\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := EnvOverrides(func(key string) (string, bool) {
+		value, ok := map[string]string{
+			"BEATPORTDL_QUALITY":     "high",
+			"BEATPORTDL_RETRY_DELAY": "2s",
+			"BEATPORTDL_FIX_TAGS":    "false",
+		}[key]
+		return value, ok
+	})
+	flags := Overrides{"quality": "lossless", "tag_mappings": "flac: {track_name: NAME}"}
+
+	cfg, err := Parse(path, "", env, flags)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if cfg.Quality != "lossless" {
+		t.Errorf("quality = %q, want the flag value", cfg.Quality)
+	}
+	if cfg.RetryDelay != 2*time.Second || cfg.FixTags {
+		t.Errorf("retry_delay = %s, fix_tags = %v, want the env values", cfg.RetryDelay, cfg.FixTags)
+	}
+	if cfg.MaxDownloadWorkers != 5 || cfg.DownloadsDirectory != "/music" {
+		t.Errorf("file values were not kept: %+v", cfg)
+	}
+	if cfg.MaxSegmentWorkers != 4 {
+		t.Errorf("max_segment_workers = %d, want the default", cfg.MaxSegmentWorkers)
+	}
+	if cfg.TagMappings["flac"]["track_name"] != "NAME" || cfg.TagMappings["m4a"] == nil {
+		t.Errorf("tag_mappings = %v", cfg.TagMappings)
+	}
+
+	if _, err := Parse(path, "", Overrides{"max_download_workers": "many"}); err == nil {
+		t.Errorf("Parse() accepted an invalid integer")
+	}
+}
+
+func TestParseWithoutFile(t *testing.T) {
+	option, ok := LookupOption("dir")
+	if !ok || option.Key != "downloads_directory" || option.Env != "BEATPORTDL_DOWNLOADS_DIRECTORY" {
+		t.Fatalf("LookupOption(dir) = %+v, %v", option, ok)
+	}
+
+	cfg, err := Parse("", "", Overrides{"username": "user", "password": "pass", option.Key: "/music"})
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if cfg.DownloadsDirectory != "/music" || cfg.Quality != "lossless" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beatportdl-config.yml")
+	data := "username: user\npassword: pass\ndownloads_directory: /music\nquality: lossless\n" +
+		"profiles:\n" +
+		"  promo:\n    quality: high\n    downloads_directory: /promo\n    username: other\n" +
+		"  broken:\n    quality: high\n    qualty: lossless\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Parse(path, "")
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if cfg.Quality != "lossless" || cfg.DownloadsDirectory != "/music" {
+		t.Errorf("profile applied without being selected: %+v", cfg)
+	}
+
+	cfg, err = Parse(path, "promo", Overrides{"downloads_directory": "/flag"})
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if cfg.Quality != "high" || cfg.Username != "other" || cfg.Password != "pass" {
+		t.Errorf("profile values were not applied on top of the file: %+v", cfg)
+	}
+	if cfg.DownloadsDirectory != "/flag" {
+		t.Errorf("downloads_directory = %q, want the flag to take precedence", cfg.DownloadsDirectory)
+	}
+
+	for _, profile := range []string{"missing", "broken", "../promo"} {
+		if _, err := Parse(path, profile); err == nil {
+			t.Errorf("Parse() accepted profile %q", profile)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"unspok3n/beatportdl/internal/validator"
 )
 
@@ -11,10 +12,13 @@ func ValidateTagMappings(m map[string]map[string]string) error {
 			return fmt.Errorf("invalid tag mapping format '%s'", format)
 		}
 
-		for field := range mappings {
+		for field, tag := range mappings {
 			if !validator.PermittedValue(field, SupportedTagMappingFields...) {
 				return fmt.Errorf("invalid tag mapping field '%s'", field)
 			}
+			if format == "id3v2" && !id3v2FrameIDRegexp.MatchString(tag) {
+				return fmt.Errorf("invalid id3v2 frame '%s', expected a text frame id or TXXX:<description>", tag)
+			}
 		}
 	}
 	return nil
@@ -24,8 +28,22 @@ var (
 	SupportedTagMappingFormats = []string{
 		"flac",
 		"m4a",
+		"id3v2",
+		"mp3",
+		"aiff",
+		"wav",
+	}
+
+	// ID3v2TagMappingFormats are the containers tagged with the id3v2 frame
+	// mappings, unless they have mappings of their own
+	ID3v2TagMappingFormats = []string{
+		"mp3",
+		"aiff",
+		"wav",
 	}
 
+	id3v2FrameIDRegexp = regexp.MustCompile(`^(T[A-Z0-9]{3}|TXXX:.+)$`)
+
 	SupportedTagMappingFields = []string{
 		"track_id",
 		"track_url",
@@ -71,7 +89,9 @@ var (
 			"track_key":               "KEY",
 			"track_bpm":               "BPM",
 			"track_isrc":              "ISRC",
+			"track_id":                "BEATPORT_TRACK_ID",
 
+			"release_id":             "BEATPORT_RELEASE_ID",
 			"release_name":           "ALBUM",
 			"release_artists":        "ALBUMARTIST",
 			"release_date":           "DATE",
@@ -87,7 +107,9 @@ var (
 			"track_key":     "KEY",
 			"track_bpm":     "BPM",
 			"track_isrc":    "ISRC",
+			"track_id":      "BEATPORT_TRACK_ID",
 
+			"release_id":             "BEATPORT_RELEASE_ID",
 			"release_name":           "ALBUM",
 			"release_artists":        "ALBUMARTIST",
 			"release_date":           "DATE",
@@ -95,5 +117,23 @@ var (
 			"release_catalog_number": "CATALOGNUMBER",
 			"release_label":          "LABEL",
 		},
+		"id3v2": {
+			"track_name":              "TIT2",
+			"track_artists":           "TPE1",
+			"track_remixers":          "TPE4",
+			"track_number_with_total": "TRCK",
+			"track_subgenre_or_genre": "TCON",
+			"track_key":               "TKEY",
+			"track_bpm":               "TBPM",
+			"track_isrc":              "TSRC",
+			"track_id":                "TXXX:BEATPORT_TRACK_ID",
+
+			"release_id":             "TXXX:BEATPORT_RELEASE_ID",
+			"release_name":           "TALB",
+			"release_artists":        "TPE2",
+			"release_date":           "TDRC",
+			"release_catalog_number": "TXXX:CATALOGNUMBER",
+			"release_label":          "TPUB",
+		},
 	}
 )
@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -38,7 +39,12 @@ func (a *Artists) Display(limit int, shortForm string) string {
 }
 
 func (b *Beatport) GetArtist(id int64) (*Artist, error) {
+	return b.GetArtistContext(context.Background(), id)
+}
+
+func (b *Beatport) GetArtistContext(ctx context.Context, id int64) (*Artist, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/artists/%d/", id),
 		nil,
@@ -56,7 +62,12 @@ func (b *Beatport) GetArtist(id int64) (*Artist, error) {
 }
 
 func (b *Beatport) GetArtistTracks(id int64, page int, params string) (*Paginated[Track], error) {
+	return b.GetArtistTracksContext(context.Background(), id, page, params)
+}
+
+func (b *Beatport) GetArtistTracksContext(ctx context.Context, id int64, page int, params string) (*Paginated[Track], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/tracks/?page=%d&artist_id=%d&%s", page, id, params),
 		nil,
@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -90,16 +91,16 @@ func (a *Auth) WriteCache() error {
 	return nil
 }
 
-func (a *Auth) Check(inst *Beatport) error {
+func (a *Auth) Check(ctx context.Context, inst *Beatport) error {
 	currentTime := time.Now().Unix()
 	a.mutex.RLock()
 	tokenExpirationTime := a.tokenPair.IssuedAt + a.tokenPair.ExpiresIn
 	a.mutex.RUnlock()
 	if currentTime+300 >= tokenExpirationTime {
 		a.mutex.Lock()
-		fmt.Println("Refreshing token")
-		if _, err := a.refresh(inst); err != nil {
-			if err = a.Init(inst); err != nil {
+		inst.logger.Info("Refreshing token")
+		if _, err := a.refresh(ctx, inst); err != nil {
+			if err = a.InitContext(ctx, inst); err != nil {
 				a.mutex.Unlock()
 				return fmt.Errorf("invalid token and authorization error: %w", err)
 			}
@@ -116,29 +117,33 @@ func (a *Auth) Invalidate() {
 }
 
 func (a *Auth) Init(inst *Beatport) error {
-	fmt.Println("Logging in")
-	sessionId, err := a.login(inst)
+	return a.InitContext(context.Background(), inst)
+}
+
+func (a *Auth) InitContext(ctx context.Context, inst *Beatport) error {
+	inst.logger.Info("Logging in")
+	sessionId, err := a.login(ctx, inst)
 	if err != nil {
 		return fmt.Errorf("login: %v", err)
 	}
-	authorizationCode, err := a.authorize(inst, sessionId)
+	authorizationCode, err := a.authorize(ctx, inst, sessionId)
 	if err != nil {
 		return fmt.Errorf("authorize: %v", err)
 	}
-	if err := a.issue(inst, authorizationCode); err != nil {
+	if err := a.issue(ctx, inst, authorizationCode); err != nil {
 		return fmt.Errorf("issue token: %v", err)
 	}
 	return nil
 }
 
-func (a *Auth) refresh(inst *Beatport) (*tokenPair, error) {
+func (a *Auth) refresh(ctx context.Context, inst *Beatport) (*tokenPair, error) {
 	payload := map[string]string{
 		"client_id":     clientId,
 		"refresh_token": a.tokenPair.RefreshToken,
 		"grant_type":    "refresh_token",
 	}
 
-	res, err := inst.fetch("POST", tokenEndpoint, payload, "application/x-www-form-urlencoded")
+	res, err := inst.fetch(ctx, "POST", tokenEndpoint, payload, "application/x-www-form-urlencoded")
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +163,7 @@ func (a *Auth) refresh(inst *Beatport) (*tokenPair, error) {
 	return response, nil
 }
 
-func (a *Auth) issue(inst *Beatport, code string) error {
+func (a *Auth) issue(ctx context.Context, inst *Beatport, code string) error {
 	payload := map[string]string{
 		"client_id": clientId,
 	}
@@ -172,7 +177,7 @@ func (a *Auth) issue(inst *Beatport, code string) error {
 		payload["password"] = a.password
 	}
 
-	res, err := inst.fetch("POST", tokenEndpoint, payload, "application/x-www-form-urlencoded")
+	res, err := inst.fetch(ctx, "POST", tokenEndpoint, payload, "application/x-www-form-urlencoded")
 	if err != nil {
 		return err
 	}
@@ -192,9 +197,9 @@ func (a *Auth) issue(inst *Beatport, code string) error {
 	return nil
 }
 
-func (a *Auth) authorize(inst *Beatport, sessionId string) (string, error) {
+func (a *Auth) authorize(ctx context.Context, inst *Beatport, sessionId string) (string, error) {
 	inst.headers["cookie"] = fmt.Sprintf("sessionid=%s", sessionId)
-	res, err := inst.fetch("GET", authEndpoint, nil, "")
+	res, err := inst.fetch(ctx, "GET", authEndpoint, nil, "")
 	delete(inst.headers, "cookie")
 	if err != nil {
 		return "", err
@@ -215,13 +220,13 @@ func (a *Auth) authorize(inst *Beatport, sessionId string) (string, error) {
 	return "", ErrInvalidAuthorizationCode
 }
 
-func (a *Auth) login(inst *Beatport) (string, error) {
+func (a *Auth) login(ctx context.Context, inst *Beatport) (string, error) {
 	payload := map[string]string{
 		"username": a.username,
 		"password": a.password,
 	}
 
-	res, err := inst.fetch("POST", loginEndpoint, payload, "application/json")
+	res, err := inst.fetch(ctx, "POST", loginEndpoint, payload, "application/json")
 	if err != nil {
 		return "", err
 	}
@@ -2,12 +2,16 @@ package beatport
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"time"
+	"unspok3n/beatportdl/internal/retry"
 )
 
 const (
@@ -18,8 +22,15 @@ type Beatport struct {
 	client  *http.Client
 	headers map[string]string
 	auth    *Auth
+	retry   *retry.Policy
+	limiter *rateLimiter
+	logger  *slog.Logger
 }
 
+var (
+	ErrRateLimited = errors.New("request failed with status code: 429 - rate limited")
+)
+
 type FetcherError struct {
 	Detail *string `json:"detail,omitempty"`
 	Error  *string `json:"error,omitempty"`
@@ -68,19 +79,100 @@ func New(proxyUrl string, auth *Auth) *Beatport {
 			},
 		},
 		headers: headers,
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
 	}
 	return &f
 }
 
-func (b *Beatport) fetch(method, endpoint string, payload interface{}, contentType string) (*http.Response, error) {
-	var body bytes.Buffer
+func (b *Beatport) SetRetryPolicy(policy *retry.Policy) {
+	b.retry = policy
+}
 
-	if endpoint != tokenEndpoint && endpoint != authEndpoint && endpoint != loginEndpoint {
-		if err := b.auth.Check(b); err != nil {
+// SetLogger sets the logger for the login messages and, at debug level, every
+// request with its status.
+func (b *Beatport) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+// SetRateLimit throttles API requests to the given rate, allowing short bursts
+// of up to burst requests. A non-positive rate disables the limiter.
+func (b *Beatport) SetRateLimit(requestsPerSecond float64, burst int) {
+	b.limiter = newRateLimiter(requestsPerSecond, burst)
+}
+
+func (b *Beatport) fetch(ctx context.Context, method, endpoint string, payload interface{}, contentType string) (*http.Response, error) {
+	authRequired := endpoint != tokenEndpoint && endpoint != authEndpoint && endpoint != loginEndpoint
+
+	if authRequired {
+		if err := b.auth.Check(ctx, b); err != nil {
 			return nil, err
 		}
 	}
 
+	resp, err := b.send(ctx, method, endpoint, payload, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && authRequired {
+		resp.Body.Close()
+		b.auth.Invalidate()
+		if err := b.auth.Check(ctx, b); err != nil {
+			return nil, err
+		}
+		resp, err = b.send(ctx, method, endpoint, payload, contentType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusFound {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+
+	return resp, nil
+}
+
+// send performs a single logical request, retrying transport failures and
+// transient status codes according to the retry policy.
+func (b *Beatport) send(ctx context.Context, method, endpoint string, payload interface{}, contentType string) (*http.Response, error) {
+	var resp *http.Response
+	err := b.retry.Do(ctx, endpoint, func() error {
+		req, err := b.newRequest(ctx, method, endpoint, payload, contentType)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+
+		if err := b.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		start := time.Now()
+		r, err := b.client.Do(req)
+		if err != nil {
+			b.logger.Debug("fetch", "method", method, "endpoint", endpoint, "error", err)
+			return fmt.Errorf("request failed: %w", err)
+		}
+		b.logger.Debug("fetch", "method", method, "endpoint", endpoint, "status", r.StatusCode,
+			"duration", time.Since(start).Round(time.Millisecond))
+
+		if retry.RetryableStatus(r.StatusCode) {
+			defer r.Body.Close()
+			return retry.NewStatusError(r, responseError(r))
+		}
+
+		resp = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (b *Beatport) newRequest(ctx context.Context, method, endpoint string, payload interface{}, contentType string) (*http.Request, error) {
+	var body bytes.Buffer
+
 	if payload != nil {
 		switch contentType {
 		case "application/json":
@@ -98,7 +190,7 @@ func (b *Beatport) fetch(method, endpoint string, payload interface{}, contentTy
 		}
 	}
 
-	req, err := http.NewRequest(method, beatportBaseUrl+endpoint, &body)
+	req, err := http.NewRequestWithContext(ctx, method, beatportBaseUrl+endpoint, &body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -115,35 +207,28 @@ func (b *Beatport) fetch(method, endpoint string, payload interface{}, contentTy
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", b.auth.tokenPair.AccessToken))
 	}
 
-	resp, err := b.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
+	return req, nil
+}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusFound {
-		if resp.StatusCode == http.StatusUnauthorized && endpoint != tokenEndpoint && endpoint != authEndpoint && endpoint != loginEndpoint {
-			b.auth.Invalidate()
-			return b.fetch(method, endpoint, payload, contentType)
-		}
-		defer resp.Body.Close()
-		response := &FetcherError{}
-		if err = json.NewDecoder(resp.Body).Decode(response); err == nil {
-			detail := "Unknown error"
-			if response.Detail != nil {
-				detail = *response.Detail
-			} else if response.Error != nil {
-				detail = *response.Error
-			}
-			return nil, fmt.Errorf(
-				"request failed with status code: %d - %s",
-				resp.StatusCode,
-				detail,
-			)
+func responseError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return ErrRateLimited
+	}
+	response := &FetcherError{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err == nil {
+		detail := "Unknown error"
+		if response.Detail != nil {
+			detail = *response.Detail
+		} else if response.Error != nil {
+			detail = *response.Error
 		}
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+		return fmt.Errorf(
+			"request failed with status code: %d - %s",
+			resp.StatusCode,
+			detail,
+		)
 	}
-
-	return resp, nil
+	return fmt.Errorf("request failed with status code: %d", resp.StatusCode)
 }
 
 func encodeFormPayload(payload interface{}) (url.Values, error) {
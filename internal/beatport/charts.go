@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -46,7 +47,12 @@ func (c *Chart) DirectoryName(n NamingPreferences) string {
 }
 
 func (b *Beatport) GetChart(id int64) (*Chart, error) {
+	return b.GetChartContext(context.Background(), id)
+}
+
+func (b *Beatport) GetChartContext(ctx context.Context, id int64) (*Chart, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/charts/%d/", id),
 		nil,
@@ -64,7 +70,12 @@ func (b *Beatport) GetChart(id int64) (*Chart, error) {
 }
 
 func (b *Beatport) GetChartTracks(id int64, page int, params string) (*Paginated[Track], error) {
+	return b.GetChartTracksContext(context.Background(), id, page, params)
+}
+
+func (b *Beatport) GetChartTracksContext(ctx context.Context, id int64, page int, params string) (*Paginated[Track], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/charts/%d/tracks/?page=%d&%s", id, page, params),
 		nil,
@@ -1,7 +1,9 @@
 package beatport
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 )
 
 type Key struct {
@@ -54,3 +56,137 @@ func (k *Key) Display(system string) string {
 		return ""
 	}
 }
+
+var (
+	ErrInvalidKey = errors.New("invalid key")
+
+	// Pitch class names used when a key is parsed rather than fetched from the API
+	majorKeyNames = [12]string{"C", "Db", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+	minorKeyNames = [12]string{"C", "C#", "D", "Eb", "E", "F", "F#", "G", "G#", "A", "Bb", "B"}
+
+	letterPitchClasses = map[byte]int{
+		'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11,
+	}
+)
+
+// PitchClass returns the tonic of the key as a number of semitones above C
+// (0-11) and whether the key is minor.
+func (k *Key) PitchClass() (int, bool, error) {
+	if len(k.Letter) != 1 {
+		return 0, false, ErrInvalidKey
+	}
+	pitchClass, ok := letterPitchClasses[k.Letter[0]]
+	if !ok {
+		return 0, false, ErrInvalidKey
+	}
+	if k.IsSharp {
+		pitchClass++
+	} else if k.IsFlat {
+		pitchClass--
+	}
+	return (pitchClass + 12) % 12, k.ChordType.Name == "Minor", nil
+}
+
+// ParseKey is the inverse of Key.Display: it parses a key written in the given
+// key system, e.g. read back from a tag.
+func ParseKey(s string, system string) (*Key, error) {
+	s = strings.TrimSpace(s)
+	switch system {
+	case "standard", "standard-short":
+		return parseStandardKey(s)
+	case "openkey":
+		if len(s) < 2 {
+			return nil, ErrInvalidKey
+		}
+		number, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || number < 1 || number > 12 {
+			return nil, ErrInvalidKey
+		}
+		camelotNumber := number - 5
+		if number <= 5 {
+			camelotNumber = number + 7
+		}
+		switch strings.ToLower(s[len(s)-1:]) {
+		case "m":
+			return keyFromCamelot(camelotNumber, "A"), nil
+		case "d":
+			return keyFromCamelot(camelotNumber, "B"), nil
+		}
+		return nil, ErrInvalidKey
+	case "camelot":
+		if len(s) < 2 {
+			return nil, ErrInvalidKey
+		}
+		number, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || number < 1 || number > 12 {
+			return nil, ErrInvalidKey
+		}
+		letter := strings.ToUpper(s[len(s)-1:])
+		if letter != "A" && letter != "B" {
+			return nil, ErrInvalidKey
+		}
+		return keyFromCamelot(number, letter), nil
+	default:
+		return nil, ErrInvalidKey
+	}
+}
+
+func parseStandardKey(s string) (*Key, error) {
+	if s == "" {
+		return nil, ErrInvalidKey
+	}
+	pitchClass, ok := letterPitchClasses[strings.ToUpper(s[:1])[0]]
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	rest := s[1:]
+	switch {
+	case strings.HasPrefix(rest, "#"), strings.HasPrefix(rest, "♯"):
+		pitchClass++
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, "#"), "♯")
+	case strings.HasPrefix(rest, "b"), strings.HasPrefix(rest, "♭"):
+		pitchClass--
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, "b"), "♭")
+	}
+	pitchClass = (pitchClass + 12) % 12
+
+	rest = strings.ToLower(strings.TrimSpace(rest))
+	switch rest {
+	case "", "maj", "major":
+		return keyFromPitchClass(pitchClass, false), nil
+	case "m", "min", "minor":
+		return keyFromPitchClass(pitchClass, true), nil
+	}
+	return nil, ErrInvalidKey
+}
+
+func keyFromPitchClass(pitchClass int, minor bool) *Key {
+	name := majorKeyNames[pitchClass]
+	chord := "Major"
+	camelotLetter := "B"
+	// Relative majors share the camelot number, each fifth up adds one
+	majorPitchClass := pitchClass
+	if minor {
+		name = minorKeyNames[pitchClass]
+		chord = "Minor"
+		camelotLetter = "A"
+		majorPitchClass = (pitchClass + 3) % 12
+	}
+	return &Key{
+		Name:          name + " " + chord,
+		Letter:        name[:1],
+		ChordType:     ChordType{Name: chord},
+		CamelotNumber: (majorPitchClass*7%12+7)%12 + 1,
+		CamelotLetter: camelotLetter,
+		IsFlat:        strings.HasSuffix(name, "b"),
+		IsSharp:       strings.HasSuffix(name, "#"),
+	}
+}
+
+func keyFromCamelot(number int, letter string) *Key {
+	majorPitchClass := ((number-8)*7%12 + 12) % 12
+	if letter == "A" {
+		return keyFromPitchClass((majorPitchClass+9)%12, true)
+	}
+	return keyFromPitchClass(majorPitchClass, false)
+}
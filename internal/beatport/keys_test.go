@@ -0,0 +1,54 @@
+package beatport
+
+import "testing"
+
+func TestParseKey(t *testing.T) {
+	systems := []string{"standard", "standard-short", "openkey", "camelot"}
+	for pitchClass := 0; pitchClass < 12; pitchClass++ {
+		for _, minor := range []bool{false, true} {
+			key := keyFromPitchClass(pitchClass, minor)
+			for _, system := range systems {
+				display := key.Display(system)
+				parsed, err := ParseKey(display, system)
+				if err != nil {
+					t.Fatalf("ParseKey(%q, %q) error: %v", display, system, err)
+				}
+				gotPitchClass, gotMinor, err := parsed.PitchClass()
+				if err != nil || gotPitchClass != pitchClass || gotMinor != minor {
+					t.Errorf("ParseKey(%q, %q) = %d minor=%v, want %d minor=%v",
+						display, system, gotPitchClass, gotMinor, pitchClass, minor)
+				}
+			}
+		}
+	}
+
+	cases := []struct {
+		input      string
+		system     string
+		pitchClass int
+		minor      bool
+		camelot    string
+	}{
+		{"A Minor", "standard", 9, true, "8A"},
+		{"C Major", "standard", 0, false, "8B"},
+		{"F#m", "standard-short", 6, true, "11A"},
+		{"Bb", "standard-short", 10, false, "6B"},
+		{"1d", "openkey", 0, false, "8B"},
+		{"12A", "camelot", 1, true, "12A"},
+	}
+	for _, c := range cases {
+		key, err := ParseKey(c.input, c.system)
+		if err != nil {
+			t.Fatalf("ParseKey(%q, %q) error: %v", c.input, c.system, err)
+		}
+		pitchClass, minor, _ := key.PitchClass()
+		if pitchClass != c.pitchClass || minor != c.minor || key.Display("camelot") != c.camelot {
+			t.Errorf("ParseKey(%q, %q) = %d minor=%v %s, want %d minor=%v %s",
+				c.input, c.system, pitchClass, minor, key.Display("camelot"), c.pitchClass, c.minor, c.camelot)
+		}
+	}
+
+	if _, err := ParseKey("H", "standard-short"); err == nil {
+		t.Error("expected error for invalid key")
+	}
+}
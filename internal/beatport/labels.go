@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -32,7 +33,12 @@ func (l *Label) StoreUrl() string {
 }
 
 func (b *Beatport) GetLabel(id int64) (*Label, error) {
+	return b.GetLabelContext(context.Background(), id)
+}
+
+func (b *Beatport) GetLabelContext(ctx context.Context, id int64) (*Label, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/labels/%d/", id),
 		nil,
@@ -50,7 +56,12 @@ func (b *Beatport) GetLabel(id int64) (*Label, error) {
 }
 
 func (b *Beatport) GetLabelReleases(id int64, page int, params string) (*Paginated[Release], error) {
+	return b.GetLabelReleasesContext(context.Background(), id, page, params)
+}
+
+func (b *Beatport) GetLabelReleasesContext(ctx context.Context, id int64, page int, params string) (*Paginated[Release], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/labels/%d/releases/?page=%d&%s", id, page, params),
 		nil,
@@ -68,7 +79,12 @@ func (b *Beatport) GetLabelReleases(id int64, page int, params string) (*Paginat
 }
 
 func (b *Beatport) GetLabelTracks(id int64, page int, params string) (*Paginated[Track], error) {
+	return b.GetLabelTracksContext(context.Background(), id, page, params)
+}
+
+func (b *Beatport) GetLabelTracksContext(ctx context.Context, id int64, page int, params string) (*Paginated[Track], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/tracks/?page=%d&label_id=%d&%s", page, id, params),
 		nil,
@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -51,7 +52,12 @@ func (p *Playlist) DirectoryName(n NamingPreferences) string {
 }
 
 func (b *Beatport) GetPlaylist(id int64) (*Playlist, error) {
+	return b.GetPlaylistContext(context.Background(), id)
+}
+
+func (b *Beatport) GetPlaylistContext(ctx context.Context, id int64) (*Playlist, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/playlists/%d/", id),
 		nil,
@@ -69,7 +75,12 @@ func (b *Beatport) GetPlaylist(id int64) (*Playlist, error) {
 }
 
 func (b *Beatport) GetPlaylistItems(id int64, page int, params string) (*Paginated[PlaylistItem], error) {
+	return b.GetPlaylistItemsContext(context.Background(), id, page, params)
+}
+
+func (b *Beatport) GetPlaylistItemsContext(ctx context.Context, id int64, page int, params string) (*Paginated[PlaylistItem], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/playlists/%d/tracks/?page=%d&%s", id, page, params),
 		nil,
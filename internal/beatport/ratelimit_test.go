@@ -0,0 +1,61 @@
+package beatport
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept []time.Duration
+
+	l := newRateLimiter(2, 3)
+	l.last = now
+	l.now = func() time.Time { return now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		l.Wait(context.Background())
+	}
+	if len(slept) != 0 {
+		t.Fatalf("burst requests waited: %v", slept)
+	}
+
+	l.Wait(context.Background())
+	l.Wait(context.Background())
+	want := []time.Duration{500 * time.Millisecond, time.Second}
+	if len(slept) != len(want) || slept[0] != want[0] || slept[1] != want[1] {
+		t.Fatalf("waits = %v, want %v", slept, want)
+	}
+
+	// Two seconds later the reserved tokens are paid back and two more are available.
+	now = now.Add(2 * time.Second)
+	slept = nil
+	l.Wait(context.Background())
+	if len(slept) != 0 {
+		t.Fatalf("refilled request waited: %v", slept)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := newRateLimiter(1, 1)
+	l.Wait(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := newRateLimiter(0, 10)
+	if l != nil {
+		t.Fatalf("newRateLimiter(0) = %v, want nil", l)
+	}
+	l.Wait(context.Background())
+}
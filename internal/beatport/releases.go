@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -34,7 +35,12 @@ func (r *Release) StoreUrl() string {
 }
 
 func (b *Beatport) GetRelease(id int64) (*Release, error) {
+	return b.GetReleaseContext(context.Background(), id)
+}
+
+func (b *Beatport) GetReleaseContext(ctx context.Context, id int64) (*Release, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/releases/%d/", id),
 		nil,
@@ -52,7 +58,12 @@ func (b *Beatport) GetRelease(id int64) (*Release, error) {
 }
 
 func (b *Beatport) GetReleaseTracks(id int64, page int, params string) (*Paginated[Track], error) {
+	return b.GetReleaseTracksContext(context.Background(), id, page, params)
+}
+
+func (b *Beatport) GetReleaseTracksContext(ctx context.Context, id int64, page int, params string) (*Paginated[Track], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/releases/%d/tracks/?page=%d&%s", id, page, params),
 		nil,
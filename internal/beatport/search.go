@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -13,7 +14,12 @@ type SearchResults struct {
 }
 
 func (b *Beatport) Search(query string) (*SearchResults, error) {
+	return b.SearchContext(context.Background(), query)
+}
+
+func (b *Beatport) SearchContext(ctx context.Context, query string) (*SearchResults, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/search/?q=%s&order_by=-publish_date&is_available_for_streaming=true", url.QueryEscape(query)),
 		nil,
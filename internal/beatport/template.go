@@ -0,0 +1,275 @@
+package beatport
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Naming templates substitute {key} placeholders with the values of an entity.
+// A placeholder can pass its value through filters and fall back to a default
+// when the value is empty:
+//
+//	{name|upper}  {artists|truncate:40}  {date|format:2006}  {remixers?Unknown}
+//
+// Text between < and > is an optional section, which is left out when one of
+// its placeholders is empty, e.g. "{name}< ({mix_name|omit:Original Mix})>".
+
+var (
+	ErrInvalidTemplate = errors.New("invalid template")
+)
+
+var (
+	TrackTemplateKeys    = []string{"id", "name", "mix_name", "slug", "artists", "remixers", "number", "length", "key", "bpm", "genre", "subgenre", "genre_with_subgenre", "subgenre_or_genre", "isrc", "label"}
+	ReleaseTemplateKeys  = []string{"id", "name", "slug", "artists", "remixers", "date", "year", "track_count", "bpm_range", "catalog_number", "upc", "label"}
+	PlaylistTemplateKeys = []string{"id", "name", "first_genre", "track_count", "bpm_range", "length", "created_date", "updated_date"}
+	ChartTemplateKeys    = []string{"id", "name", "slug", "first_genre", "track_count", "creator", "created_date", "published_date", "updated_date"}
+	ArtistTemplateKeys   = []string{"id", "name", "slug"}
+	LabelTemplateKeys    = []string{"id", "name", "slug", "created_date", "updated_date"}
+)
+
+const templateDateLayout = "2006-01-02"
+
+type templateFilter struct {
+	name string
+	arg  string
+}
+
+type templatePlaceholder struct {
+	raw     string
+	key     string
+	filters []templateFilter
+	def     string
+}
+
+// templatePart is either literal text or a placeholder.
+type templatePart struct {
+	text        string
+	placeholder *templatePlaceholder
+}
+
+// templateSection is a run of parts, optional when it was written in <>.
+type templateSection struct {
+	parts    []templatePart
+	optional bool
+}
+
+type compiledTemplate []templateSection
+
+// Templates come from the config and are rendered for every file and
+// directory, so they are compiled once and reused.
+var compiledTemplates sync.Map
+
+func compileTemplate(template string) (compiledTemplate, error) {
+	if cached, ok := compiledTemplates.Load(template); ok {
+		return cached.(compiledTemplate), nil
+	}
+
+	var (
+		result  compiledTemplate
+		current = templateSection{}
+		text    strings.Builder
+	)
+	flushText := func() {
+		if text.Len() > 0 {
+			current.parts = append(current.parts, templatePart{text: text.String()})
+			text.Reset()
+		}
+	}
+	flushSection := func() {
+		flushText()
+		if len(current.parts) > 0 {
+			result = append(result, current)
+		}
+		current = templateSection{}
+	}
+
+	for i := 0; i < len(template); i++ {
+		switch c := template[i]; c {
+		case '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unclosed placeholder at position %d", ErrInvalidTemplate, i+1)
+			}
+			placeholder, err := parsePlaceholder(template[i : i+end+1])
+			if err != nil {
+				return nil, err
+			}
+			flushText()
+			current.parts = append(current.parts, templatePart{placeholder: placeholder})
+			i += end
+		case '<':
+			if current.optional {
+				return nil, fmt.Errorf("%w: nested optional section at position %d", ErrInvalidTemplate, i+1)
+			}
+			flushSection()
+			current.optional = true
+		case '>':
+			if !current.optional {
+				return nil, fmt.Errorf("%w: unexpected '>' at position %d", ErrInvalidTemplate, i+1)
+			}
+			flushSection()
+		default:
+			text.WriteByte(c)
+		}
+	}
+	if current.optional {
+		return nil, fmt.Errorf("%w: unclosed optional section", ErrInvalidTemplate)
+	}
+	flushSection()
+
+	compiledTemplates.Store(template, result)
+	return result, nil
+}
+
+// parsePlaceholder parses "{key|filter:arg|filter?default}".
+func parsePlaceholder(raw string) (*templatePlaceholder, error) {
+	body := raw[1 : len(raw)-1]
+	p := &templatePlaceholder{raw: raw}
+
+	if i := strings.IndexByte(body, '?'); i >= 0 {
+		body, p.def = body[:i], body[i+1:]
+	}
+
+	fields := strings.Split(body, "|")
+	p.key = strings.TrimSpace(fields[0])
+	if p.key == "" {
+		return nil, fmt.Errorf("%w: empty placeholder %s", ErrInvalidTemplate, raw)
+	}
+	for _, field := range fields[1:] {
+		name, arg, _ := strings.Cut(field, ":")
+		filter := templateFilter{name: strings.TrimSpace(name), arg: arg}
+		if err := filter.validate(); err != nil {
+			return nil, fmt.Errorf("%w: %s in %s", ErrInvalidTemplate, err, raw)
+		}
+		p.filters = append(p.filters, filter)
+	}
+	return p, nil
+}
+
+func (f templateFilter) validate() error {
+	switch f.name {
+	case "upper", "lower":
+		return nil
+	case "truncate":
+		if n, err := strconv.Atoi(f.arg); err != nil || n < 1 {
+			return fmt.Errorf("truncate needs a positive length")
+		}
+		return nil
+	case "format":
+		if f.arg == "" {
+			return fmt.Errorf("format needs a date layout")
+		}
+		return nil
+	case "omit":
+		if f.arg == "" {
+			return fmt.Errorf("omit needs a value")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown filter '%s'", f.name)
+}
+
+func (f templateFilter) apply(value string) string {
+	switch f.name {
+	case "upper":
+		return strings.ToUpper(value)
+	case "lower":
+		return strings.ToLower(value)
+	case "truncate":
+		n, _ := strconv.Atoi(f.arg)
+		if utf8.RuneCountInString(value) > n {
+			value = strings.TrimSpace(string([]rune(value)[:n]))
+		}
+		return value
+	case "format":
+		if t, err := time.Parse(templateDateLayout, value); err == nil {
+			return t.Format(f.arg)
+		}
+		return value
+	case "omit":
+		if strings.EqualFold(value, f.arg) {
+			return ""
+		}
+		return value
+	}
+	return value
+}
+
+// render returns the value of the placeholder and whether it is known.
+func (p *templatePlaceholder) render(values map[string]string) (string, bool) {
+	value, found := values[p.key]
+	if !found {
+		return p.raw, false
+	}
+	for _, filter := range p.filters {
+		value = filter.apply(value)
+	}
+	if value == "" {
+		value = p.def
+	}
+	return value, true
+}
+
+func ParseTemplate(template string, values map[string]string) string {
+	compiled, err := compileTemplate(template)
+	if err != nil {
+		// Templates are validated with the config, this keeps an invalid one
+		// from the old single placeholder syntax working
+		return templatePlaceholderRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+			if value, found := values[strings.Trim(placeholder, "{}")]; found {
+				return value
+			}
+			return placeholder
+		})
+	}
+
+	var result strings.Builder
+	var section strings.Builder
+	for _, s := range compiled {
+		section.Reset()
+		empty := false
+		for _, part := range s.parts {
+			if part.placeholder == nil {
+				section.WriteString(part.text)
+				continue
+			}
+			// Unknown placeholders are left intact
+			value, known := part.placeholder.render(values)
+			if known && value == "" {
+				empty = true
+			}
+			section.WriteString(value)
+		}
+		if s.optional && empty {
+			continue
+		}
+		result.WriteString(section.String())
+	}
+	return result.String()
+}
+
+// ValidateTemplate checks the syntax of a template and that it only uses the
+// given keys.
+func ValidateTemplate(template string, keys []string) error {
+	compiled, err := compileTemplate(template)
+	if err != nil {
+		return err
+	}
+	for _, s := range compiled {
+		for _, part := range s.parts {
+			if part.placeholder == nil {
+				continue
+			}
+			if !slices.Contains(keys, part.placeholder.key) {
+				return fmt.Errorf("%w: unknown placeholder %s", ErrInvalidTemplate, part.placeholder.raw)
+			}
+		}
+	}
+	return nil
+}
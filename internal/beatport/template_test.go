@@ -0,0 +1,99 @@
+package beatport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplateSyntax(t *testing.T) {
+	values := map[string]string{
+		"number":   "01",
+		"artists":  "Deadmau5, Kaskade",
+		"name":     "I Remember",
+		"mix_name": "Original Mix",
+		"remixers": "",
+		"date":     "2008-06-30",
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{name|upper}", "I REMEMBER"},
+		{"{name|lower}", "i remember"},
+		{"{artists|truncate:8}", "Deadmau5"},
+		{"{artists|truncate:9}", "Deadmau5,"},
+		{"{date|format:2006}", "2008"},
+		{"{date|format:Jan 2006}", "Jun 2008"},
+		{"{remixers?Unknown}", "Unknown"},
+		{"{name?Unknown}", "I Remember"},
+		{"{name}< ({mix_name})>", "I Remember (Original Mix)"},
+		{"{name}< ({mix_name|omit:original mix})>", "I Remember"},
+		{"{name}< [{remixers}]>< {date|format:2006}>", "I Remember 2008"},
+		{"<{remixers} - >{name|upper|truncate:1}", "I"},
+		{"{name} {unknown}", "I Remember {unknown}"},
+		{"[{number}] {name}", "[01] I Remember"},
+	}
+	for _, tt := range tests {
+		if got := ParseTemplate(tt.template, values); got != tt.want {
+			t.Errorf("ParseTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	valid := []string{
+		"{number}. {artists} - {name}< ({mix_name|omit:Original Mix})>",
+		"{name|upper|truncate:40}",
+		"{remixers?Unknown}",
+	}
+	for _, template := range valid {
+		if err := ValidateTemplate(template, TrackTemplateKeys); err != nil {
+			t.Errorf("ValidateTemplate(%q) = %v", template, err)
+		}
+	}
+
+	invalid := []string{
+		"{name} {unknown}",
+		"{name",
+		"{name|reverse}",
+		"{name|truncate:0}",
+		"{date|format:}",
+		"<{name}",
+		"{name}>",
+		"<<{name}>>",
+		"{}",
+	}
+	for _, template := range invalid {
+		if err := ValidateTemplate(template, TrackTemplateKeys); !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("ValidateTemplate(%q) = %v, want %v", template, err, ErrInvalidTemplate)
+		}
+	}
+}
+
+func TestTemplateKeys(t *testing.T) {
+	placeholders := func(keys []string) string {
+		var b strings.Builder
+		for _, key := range keys {
+			b.WriteString("{" + key + "}")
+		}
+		return b.String()
+	}
+	tests := []struct {
+		entity string
+		got    string
+	}{
+		{"track", (&Track{}).Filename(NamingPreferences{Template: placeholders(TrackTemplateKeys)})},
+		{"release", (&Release{}).DirectoryName(NamingPreferences{Template: placeholders(ReleaseTemplateKeys)})},
+		{"playlist", (&Playlist{}).DirectoryName(NamingPreferences{Template: placeholders(PlaylistTemplateKeys)})},
+		{"chart", (&Chart{}).DirectoryName(NamingPreferences{Template: placeholders(ChartTemplateKeys)})},
+		{"artist", (&Artist{}).DirectoryName(NamingPreferences{Template: placeholders(ArtistTemplateKeys)})},
+		{"label", (&Label{}).DirectoryName(NamingPreferences{Template: placeholders(LabelTemplateKeys)})},
+	}
+	for _, tt := range tests {
+		if strings.Contains(tt.got, "{") {
+			t.Errorf("%s template keys are not all substituted: %q", tt.entity, tt.got)
+		}
+	}
+}
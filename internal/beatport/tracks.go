@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -87,7 +88,12 @@ func (t *Track) Filename(n NamingPreferences) string {
 }
 
 func (b *Beatport) GetTrack(id int64) (*Track, error) {
+	return b.GetTrackContext(context.Background(), id)
+}
+
+func (b *Beatport) GetTrackContext(ctx context.Context, id int64) (*Track, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/tracks/%d/", id),
 		nil,
@@ -105,7 +111,12 @@ func (b *Beatport) GetTrack(id int64) (*Track, error) {
 }
 
 func (b *Beatport) DownloadTrack(id int64, quality string) (*TrackDownload, error) {
+	return b.DownloadTrackContext(context.Background(), id, quality)
+}
+
+func (b *Beatport) DownloadTrackContext(ctx context.Context, id int64, quality string) (*TrackDownload, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf(
 			"/catalog/tracks/%d/download/?quality=%s",
@@ -127,7 +138,12 @@ func (b *Beatport) DownloadTrack(id int64, quality string) (*TrackDownload, erro
 }
 
 func (b *Beatport) StreamTrack(id int64) (*TrackStream, error) {
+	return b.StreamTrackContext(context.Background(), id)
+}
+
+func (b *Beatport) StreamTrackContext(ctx context.Context, id int64) (*TrackStream, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf(
 			"/catalog/tracks/%d/stream/",
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type SanitizedString string
@@ -73,9 +74,29 @@ func SanitizeForPath(s string) string {
 	return strings.Join(strings.Fields(sanitizeForPathReplacer.Replace(s)), " ")
 }
 
+// SanitizePath sanitizes a name rendered from a template. Templates may nest
+// directories with "/", so every component is sanitized on its own and
+// empty, "." and ".." components are left out.
 func SanitizePath(name string, whitespace string) string {
+	components := strings.Split(name, "/")
+	sanitized := components[:0]
+	for _, component := range components {
+		component = sanitizePathComponent(component, whitespace)
+		if component == "" || component == "." || component == ".." {
+			continue
+		}
+		sanitized = append(sanitized, component)
+	}
+	return strings.Join(sanitized, "/")
+}
+
+func sanitizePathComponent(name string, whitespace string) string {
 	if len(name) > 250 {
 		name = name[:250]
+		// don't leave half of a multi-byte character behind
+		for !utf8.ValidString(name) {
+			name = name[:len(name)-1]
+		}
 	}
 
 	name = sanitizePathReplacer.Replace(name)
@@ -93,17 +114,6 @@ func NumberWithPadding(value, total, padding int) string {
 	return fmt.Sprintf("%0*d", padding, value)
 }
 
-func ParseTemplate(template string, values map[string]string) string {
-	result := templatePlaceholderRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
-		key := strings.Trim(placeholder, "{}")
-		if value, found := values[key]; found {
-			return value
-		}
-		return placeholder
-	})
-	return result
-}
-
 func storeUrl(id int64, entity, slug string) string {
 	return fmt.Sprintf("https://www.beatport.com/%s/%s/%d", entity, slug, id)
 }
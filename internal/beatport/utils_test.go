@@ -55,6 +55,28 @@ func TestSanitizePath(t *testing.T) {
 	if got != want {
 		t.Fatalf("SanitizePath(whitespace) = %q, want %q", got, want)
 	}
+
+	got = SanitizePath("Label: One /2008/ /../[CAT 1] Name?", "")
+	want = "Label One/2008/[CAT 1] Name"
+	if got != want {
+		t.Fatalf("SanitizePath(nested) = %q, want %q", got, want)
+	}
+}
+
+func TestReleaseDirectoryNameNested(t *testing.T) {
+	release := &Release{
+		Name:          "Strobe",
+		Date:          "2009-09-22",
+		CatalogNumber: "MAU5012",
+		Label:         Label{Name: "mau5trap / Virgin"},
+	}
+	got := release.DirectoryName(NamingPreferences{
+		Template: "{label}/{date|format:2006}/[{catalog_number}] {name}",
+	})
+	want := "mau5trap Virgin/2009/[MAU5012] Strobe"
+	if got != want {
+		t.Fatalf("DirectoryName() = %q, want %q", got, want)
+	}
 }
 
 func TestSanitizedStringUnmarshal(t *testing.T) {
@@ -0,0 +1,147 @@
+// Package collection exports downloaded tracks and playlists into the
+// collection formats of DJ software.
+package collection
+
+import (
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// Track is a downloaded track with the metadata DJ software needs to index it
+// without analysing or tagging it by hand.
+type Track struct {
+	Location    string
+	Title       string
+	MixName     string
+	Artists     string
+	Remixers    string
+	Album       string
+	Number      int
+	Genre       string
+	Label       string
+	Key         string
+	MusicalKey  *beatport.Key
+	BPM         int
+	ReleaseDate string
+	ISRC        string
+	Length      time.Duration
+	Size        int64
+}
+
+// Playlist is an ordered list of track locations.
+type Playlist struct {
+	Name      string
+	Locations []string
+}
+
+// Session collects the tracks and playlists of a download run. It is safe for
+// concurrent use; a nil Session records nothing.
+type Session struct {
+	mutex     sync.Mutex
+	tracks    map[string]Track
+	playlists []Playlist
+}
+
+func NewSession() *Session {
+	return &Session{
+		tracks: make(map[string]Track),
+	}
+}
+
+func (s *Session) AddTrack(track Track) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tracks[track.Location] = track
+}
+
+// AddPlaylist records a playlist. A playlist with the same name replaces the
+// previous one.
+func (s *Session) AddPlaylist(playlist Playlist) {
+	if s == nil || len(playlist.Locations) == 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.playlists {
+		if s.playlists[i].Name == playlist.Name {
+			s.playlists[i] = playlist
+			return
+		}
+	}
+	s.playlists = append(s.playlists, playlist)
+}
+
+// Tracks returns the recorded tracks sorted by location.
+func (s *Session) Tracks() []Track {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tracks := make([]Track, 0, len(s.tracks))
+	for _, track := range s.tracks {
+		tracks = append(tracks, track)
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].Location < tracks[j].Location
+	})
+	return tracks
+}
+
+// Playlists returns the recorded playlists in the order they were added.
+func (s *Session) Playlists() []Playlist {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	playlists := make([]Playlist, len(s.playlists))
+	copy(playlists, s.playlists)
+	return playlists
+}
+
+// Empty reports whether nothing has been recorded.
+func (s *Session) Empty() bool {
+	if s == nil {
+		return true
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.tracks) == 0 && len(s.playlists) == 0
+}
+
+// fileURL converts an absolute path to the file://localhost/ form used by
+// DJ software on every platform.
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Host: "localhost", Path: path}
+	return u.String()
+}
+
+func fileKind(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".flac":
+		return "FLAC File"
+	case ".m4a", ".mp4":
+		return "M4A File"
+	case ".mp3":
+		return "MP3 File"
+	case ".aif", ".aiff":
+		return "AIFF File"
+	case ".wav":
+		return "WAV File"
+	default:
+		return ""
+	}
+}
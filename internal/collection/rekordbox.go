@@ -0,0 +1,247 @@
+package collection
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type rekordboxDocument struct {
+	XMLName    xml.Name            `xml:"DJ_PLAYLISTS"`
+	Version    string              `xml:"Version,attr"`
+	Product    rekordboxProduct    `xml:"PRODUCT"`
+	Collection rekordboxCollection `xml:"COLLECTION"`
+	Playlists  rekordboxPlaylists  `xml:"PLAYLISTS"`
+}
+
+type rekordboxProduct struct {
+	Name    string `xml:"Name,attr"`
+	Version string `xml:"Version,attr"`
+	Company string `xml:"Company,attr"`
+}
+
+type rekordboxCollection struct {
+	Entries int              `xml:"Entries,attr"`
+	Tracks  []rekordboxTrack `xml:"TRACK"`
+}
+
+type rekordboxTrack struct {
+	TrackID     int    `xml:"TrackID,attr"`
+	Name        string `xml:"Name,attr"`
+	Artist      string `xml:"Artist,attr"`
+	Album       string `xml:"Album,attr"`
+	Genre       string `xml:"Genre,attr"`
+	Kind        string `xml:"Kind,attr"`
+	Size        int64  `xml:"Size,attr"`
+	TotalTime   int    `xml:"TotalTime,attr"`
+	TrackNumber int    `xml:"TrackNumber,attr"`
+	Year        string `xml:"Year,attr"`
+	AverageBpm  string `xml:"AverageBpm,attr"`
+	DateAdded   string `xml:"DateAdded,attr"`
+	Comments    string `xml:"Comments,attr"`
+	Location    string `xml:"Location,attr"`
+	Remixer     string `xml:"Remixer,attr"`
+	Tonality    string `xml:"Tonality,attr"`
+	Label       string `xml:"Label,attr"`
+	Mix         string `xml:"Mix,attr"`
+}
+
+type rekordboxPlaylists struct {
+	Root rekordboxNode `xml:"NODE"`
+}
+
+// rekordboxNode is a folder (Type 0) or a playlist (Type 1) node.
+type rekordboxNode struct {
+	Type    int                      `xml:"Type,attr"`
+	Name    string                   `xml:"Name,attr"`
+	Count   int                      `xml:"Count,attr,omitempty"`
+	KeyType string                   `xml:"KeyType,attr,omitempty"`
+	Entries int                      `xml:"Entries,attr,omitempty"`
+	Nodes   []rekordboxNode          `xml:"NODE"`
+	Tracks  []rekordboxPlaylistTrack `xml:"TRACK"`
+}
+
+type rekordboxPlaylistTrack struct {
+	Key int `xml:"Key,attr"`
+}
+
+const (
+	rekordboxFolderNode   = 0
+	rekordboxPlaylistNode = 1
+)
+
+// WriteRekordbox writes the session into a rekordbox DJ_PLAYLISTS XML file.
+// If the file already exists its tracks and playlists are kept, and only those
+// with the same location or name are replaced, so a single file can be
+// imported after every run.
+func WriteRekordbox(path string, s *Session) error {
+	tracks := make(map[string]rekordboxTrack)
+	var order []string
+	var playlists []Playlist
+
+	existing := &rekordboxDocument{}
+	found, err := readXML(path, existing)
+	if err != nil {
+		return err
+	}
+	if found {
+		locations := make(map[int]string)
+		for _, track := range existing.Collection.Tracks {
+			locations[track.TrackID] = track.Location
+			if _, found := tracks[track.Location]; !found {
+				order = append(order, track.Location)
+			}
+			tracks[track.Location] = track
+		}
+		for _, node := range existing.Playlists.Root.Nodes {
+			if node.Type != rekordboxPlaylistNode {
+				continue
+			}
+			playlist := Playlist{Name: node.Name}
+			for _, track := range node.Tracks {
+				if location, found := locations[track.Key]; found {
+					playlist.Locations = append(playlist.Locations, location)
+				}
+			}
+			playlists = append(playlists, playlist)
+		}
+	}
+
+	dateAdded := time.Now().Format(time.DateOnly)
+	for _, track := range s.Tracks() {
+		record := newRekordboxTrack(track, dateAdded)
+		if previous, found := tracks[record.Location]; found {
+			record.DateAdded = previous.DateAdded
+		} else {
+			order = append(order, record.Location)
+		}
+		tracks[record.Location] = record
+	}
+
+	for _, playlist := range s.Playlists() {
+		playlist.Locations = fileURLs(playlist.Locations)
+		replaced := false
+		for i := range playlists {
+			if playlists[i].Name == playlist.Name {
+				playlists[i] = playlist
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			playlists = append(playlists, playlist)
+		}
+	}
+
+	doc := rekordboxDocument{
+		Version: "1.0.0",
+		Product: rekordboxProduct{
+			Name:    "beatportdl",
+			Version: "1.0.0",
+			Company: "beatportdl",
+		},
+		Playlists: rekordboxPlaylists{
+			Root: rekordboxNode{
+				Type:  rekordboxFolderNode,
+				Name:  "ROOT",
+				Count: len(playlists),
+			},
+		},
+	}
+
+	ids := make(map[string]int, len(order))
+	for i, location := range order {
+		track := tracks[location]
+		track.TrackID = i + 1
+		ids[location] = track.TrackID
+		doc.Collection.Tracks = append(doc.Collection.Tracks, track)
+	}
+	doc.Collection.Entries = len(doc.Collection.Tracks)
+
+	for _, playlist := range playlists {
+		node := rekordboxNode{
+			Type:    rekordboxPlaylistNode,
+			Name:    playlist.Name,
+			KeyType: "0",
+		}
+		for _, location := range playlist.Locations {
+			if id, found := ids[location]; found {
+				node.Tracks = append(node.Tracks, rekordboxPlaylistTrack{Key: id})
+			}
+		}
+		node.Entries = len(node.Tracks)
+		doc.Playlists.Root.Nodes = append(doc.Playlists.Root.Nodes, node)
+	}
+
+	return writeXML(path, doc)
+}
+
+func newRekordboxTrack(track Track, dateAdded string) rekordboxTrack {
+	record := rekordboxTrack{
+		Name:        track.Title,
+		Artist:      track.Artists,
+		Album:       track.Album,
+		Genre:       track.Genre,
+		Kind:        fileKind(track.Location),
+		Size:        track.Size,
+		TotalTime:   int(track.Length / time.Second),
+		TrackNumber: track.Number,
+		AverageBpm:  strconv.Itoa(track.BPM) + ".00",
+		DateAdded:   dateAdded,
+		Location:    fileURL(track.Location),
+		Remixer:     track.Remixers,
+		Tonality:    track.Key,
+		Label:       track.Label,
+		Mix:         track.MixName,
+	}
+	if len(track.ReleaseDate) >= 4 {
+		record.Year = track.ReleaseDate[:4]
+	}
+	if track.ISRC != "" {
+		record.Comments = "ISRC " + track.ISRC
+	}
+	return record
+}
+
+func fileURLs(paths []string) []string {
+	urls := make([]string, len(paths))
+	for i, path := range paths {
+		urls[i] = fileURL(path)
+	}
+	return urls
+}
+
+// readXML decodes the file at path into doc and reports whether it existed.
+func readXML(path string, doc any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := xml.Unmarshal(data, doc); err != nil {
+		return false, fmt.Errorf("decode %s: %w", filepath.Base(path), err)
+	}
+	return true, nil
+}
+
+// writeXML writes doc to a temporary file next to path and moves it into
+// place, so an interrupted export never leaves a truncated collection behind.
+func writeXML(path string, doc any) error {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append([]byte(xml.Header), append(data, '\n')...), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,79 @@
+package collection
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteRekordbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rekordbox.xml")
+
+	first := NewSession()
+	first.AddTrack(Track{
+		Location:    "/music/Playlist/01. A - One.flac",
+		Title:       "One",
+		MixName:     "Original Mix",
+		Artists:     "A",
+		Genre:       "Techno",
+		Label:       "Label",
+		Key:         "8A",
+		BPM:         128,
+		ReleaseDate: "2024-05-17",
+		ISRC:        "GBAAA2400001",
+		Length:      6*time.Minute + 30*time.Second,
+	})
+	first.AddTrack(Track{Location: "/music/Playlist/02. B - Two.flac", Title: "Two"})
+	first.AddPlaylist(Playlist{
+		Name: "Playlist",
+		Locations: []string{
+			"/music/Playlist/02. B - Two.flac",
+			"/music/Playlist/01. A - One.flac",
+		},
+	})
+	if err := WriteRekordbox(path, first); err != nil {
+		t.Fatal(err)
+	}
+
+	second := NewSession()
+	second.AddTrack(Track{Location: "/music/Other/01. C - Three.flac", Title: "Three"})
+	second.AddPlaylist(Playlist{Name: "Other", Locations: []string{"/music/Other/01. C - Three.flac"}})
+	if err := WriteRekordbox(path, second); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc rekordboxDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Collection.Entries != 3 || len(doc.Collection.Tracks) != 3 {
+		t.Fatalf("expected 3 tracks, got %d", len(doc.Collection.Tracks))
+	}
+	one := doc.Collection.Tracks[0]
+	if one.Location != "file://localhost/music/Playlist/01.%20A%20-%20One.flac" {
+		t.Errorf("unexpected location %q", one.Location)
+	}
+	if one.AverageBpm != "128.00" || one.Tonality != "8A" || one.Year != "2024" ||
+		one.TotalTime != 390 || one.Kind != "FLAC File" || one.Comments != "ISRC GBAAA2400001" {
+		t.Errorf("unexpected track attributes %+v", one)
+	}
+
+	nodes := doc.Playlists.Root.Nodes
+	if doc.Playlists.Root.Count != 2 || len(nodes) != 2 {
+		t.Fatalf("expected 2 playlists, got %d", len(nodes))
+	}
+	if nodes[0].Name != "Playlist" || len(nodes[0].Tracks) != 2 ||
+		nodes[0].Tracks[0].Key != 2 || nodes[0].Tracks[1].Key != 1 {
+		t.Errorf("unexpected playlist %+v", nodes[0])
+	}
+	if nodes[1].Name != "Other" || len(nodes[1].Tracks) != 1 || nodes[1].Tracks[0].Key != 3 {
+		t.Errorf("unexpected playlist %+v", nodes[1])
+	}
+}
@@ -0,0 +1,284 @@
+package collection
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type traktorDocument struct {
+	XMLName    xml.Name          `xml:"NML"`
+	Version    string            `xml:"VERSION,attr"`
+	Head       traktorHead       `xml:"HEAD"`
+	Collection traktorCollection `xml:"COLLECTION"`
+	Playlists  traktorPlaylists  `xml:"PLAYLISTS"`
+}
+
+type traktorHead struct {
+	Company string `xml:"COMPANY,attr"`
+	Program string `xml:"PROGRAM,attr"`
+}
+
+type traktorCollection struct {
+	Entries int            `xml:"ENTRIES,attr"`
+	Entry   []traktorEntry `xml:"ENTRY"`
+}
+
+type traktorEntry struct {
+	ModifiedDate string             `xml:"MODIFIED_DATE,attr"`
+	Title        string             `xml:"TITLE,attr"`
+	Artist       string             `xml:"ARTIST,attr"`
+	Location     traktorLocation    `xml:"LOCATION"`
+	Album        *traktorAlbum      `xml:"ALBUM"`
+	Info         traktorInfo        `xml:"INFO"`
+	Tempo        *traktorTempo      `xml:"TEMPO"`
+	MusicalKey   *traktorMusicalKey `xml:"MUSICAL_KEY"`
+}
+
+type traktorLocation struct {
+	Dir    string `xml:"DIR,attr"`
+	File   string `xml:"FILE,attr"`
+	Volume string `xml:"VOLUME,attr"`
+}
+
+type traktorAlbum struct {
+	Track int    `xml:"TRACK,attr,omitempty"`
+	Title string `xml:"TITLE,attr"`
+}
+
+type traktorInfo struct {
+	Genre       string `xml:"GENRE,attr,omitempty"`
+	Label       string `xml:"LABEL,attr,omitempty"`
+	Key         string `xml:"KEY,attr,omitempty"`
+	Comment     string `xml:"COMMENT,attr,omitempty"`
+	Remixer     string `xml:"REMIXER,attr,omitempty"`
+	Mix         string `xml:"MIX,attr,omitempty"`
+	Playtime    int    `xml:"PLAYTIME,attr,omitempty"`
+	ImportDate  string `xml:"IMPORT_DATE,attr,omitempty"`
+	ReleaseDate string `xml:"RELEASE_DATE,attr,omitempty"`
+	FileSize    int64  `xml:"FILESIZE,attr,omitempty"`
+}
+
+type traktorTempo struct {
+	BPM        string `xml:"BPM,attr"`
+	BPMQuality string `xml:"BPM_QUALITY,attr"`
+}
+
+type traktorMusicalKey struct {
+	Value int `xml:"VALUE,attr"`
+}
+
+type traktorPlaylists struct {
+	Root traktorNode `xml:"NODE"`
+}
+
+type traktorNode struct {
+	Type     string           `xml:"TYPE,attr"`
+	Name     string           `xml:"NAME,attr"`
+	Subnodes *traktorSubnodes `xml:"SUBNODES"`
+	Playlist *traktorPlaylist `xml:"PLAYLIST"`
+}
+
+type traktorSubnodes struct {
+	Count int           `xml:"COUNT,attr"`
+	Nodes []traktorNode `xml:"NODE"`
+}
+
+type traktorPlaylist struct {
+	Entries int                    `xml:"ENTRIES,attr"`
+	Type    string                 `xml:"TYPE,attr"`
+	UUID    string                 `xml:"UUID,attr"`
+	Entry   []traktorPlaylistEntry `xml:"ENTRY"`
+}
+
+type traktorPlaylistEntry struct {
+	PrimaryKey traktorPrimaryKey `xml:"PRIMARYKEY"`
+}
+
+type traktorPrimaryKey struct {
+	Type string `xml:"TYPE,attr"`
+	Key  string `xml:"KEY,attr"`
+}
+
+const traktorDateFormat = "2006/1/2"
+
+// WriteTraktor writes the session into a Traktor NML collection. Like
+// WriteRekordbox, entries and playlists of an existing file are kept unless
+// the session replaces them.
+func WriteTraktor(path string, s *Session) error {
+	entries := make(map[string]traktorEntry)
+	var order []string
+	playlists := make(map[string]*traktorPlaylist)
+	var playlistOrder []string
+
+	existing := &traktorDocument{}
+	found, err := readXML(path, existing)
+	if err != nil {
+		return err
+	}
+	if found {
+		for _, entry := range existing.Collection.Entry {
+			key := entry.Location.primaryKey()
+			if _, found := entries[key]; !found {
+				order = append(order, key)
+			}
+			entries[key] = entry
+		}
+		if existing.Playlists.Root.Subnodes != nil {
+			for _, node := range existing.Playlists.Root.Subnodes.Nodes {
+				if node.Type != "PLAYLIST" || node.Playlist == nil {
+					continue
+				}
+				if _, found := playlists[node.Name]; !found {
+					playlistOrder = append(playlistOrder, node.Name)
+				}
+				playlists[node.Name] = node.Playlist
+			}
+		}
+	}
+
+	today := time.Now().Format(traktorDateFormat)
+	for _, track := range s.Tracks() {
+		entry := newTraktorEntry(track, today)
+		key := entry.Location.primaryKey()
+		if previous, found := entries[key]; found {
+			entry.Info.ImportDate = previous.Info.ImportDate
+		} else {
+			order = append(order, key)
+		}
+		entries[key] = entry
+	}
+
+	for _, playlist := range s.Playlists() {
+		uuid, err := newUUID()
+		if err != nil {
+			return err
+		}
+		if previous, found := playlists[playlist.Name]; found {
+			uuid = previous.UUID
+		} else {
+			playlistOrder = append(playlistOrder, playlist.Name)
+		}
+		record := &traktorPlaylist{Type: "LIST", UUID: uuid}
+		for _, location := range playlist.Locations {
+			record.Entry = append(record.Entry, traktorPlaylistEntry{
+				PrimaryKey: traktorPrimaryKey{
+					Type: "TRACK",
+					Key:  newTraktorLocation(location).primaryKey(),
+				},
+			})
+		}
+		record.Entries = len(record.Entry)
+		playlists[playlist.Name] = record
+	}
+
+	doc := traktorDocument{
+		Version: "19",
+		Head: traktorHead{
+			Company: "www.native-instruments.com",
+			Program: "Traktor",
+		},
+		Playlists: traktorPlaylists{
+			Root: traktorNode{
+				Type:     "FOLDER",
+				Name:     "$ROOT",
+				Subnodes: &traktorSubnodes{Count: len(playlistOrder)},
+			},
+		},
+	}
+	for _, key := range order {
+		doc.Collection.Entry = append(doc.Collection.Entry, entries[key])
+	}
+	doc.Collection.Entries = len(doc.Collection.Entry)
+	for _, name := range playlistOrder {
+		doc.Playlists.Root.Subnodes.Nodes = append(doc.Playlists.Root.Subnodes.Nodes, traktorNode{
+			Type:     "PLAYLIST",
+			Name:     name,
+			Playlist: playlists[name],
+		})
+	}
+
+	return writeXML(path, doc)
+}
+
+func newTraktorEntry(track Track, importDate string) traktorEntry {
+	entry := traktorEntry{
+		ModifiedDate: importDate,
+		Title:        track.Title,
+		Artist:       track.Artists,
+		Location:     newTraktorLocation(track.Location),
+		Info: traktorInfo{
+			Genre:      track.Genre,
+			Label:      track.Label,
+			Key:        track.Key,
+			Remixer:    track.Remixers,
+			Mix:        track.MixName,
+			Playtime:   int(track.Length / time.Second),
+			ImportDate: importDate,
+			FileSize:   track.Size / 1024,
+		},
+	}
+	if track.Album != "" || track.Number > 0 {
+		entry.Album = &traktorAlbum{Track: track.Number, Title: track.Album}
+	}
+	if track.ISRC != "" {
+		entry.Info.Comment = "ISRC " + track.ISRC
+	}
+	if date, err := time.Parse(time.DateOnly, track.ReleaseDate); err == nil {
+		entry.Info.ReleaseDate = date.Format(traktorDateFormat)
+	}
+	if track.BPM > 0 {
+		entry.Tempo = &traktorTempo{
+			BPM:        strconv.Itoa(track.BPM) + ".000000",
+			BPMQuality: "100.000000",
+		}
+	}
+	if track.MusicalKey != nil {
+		if pitchClass, minor, err := track.MusicalKey.PitchClass(); err == nil {
+			// Traktor numbers the major keys 0-11 and the minor keys 12-23, both from C
+			value := pitchClass
+			if minor {
+				value += 12
+			}
+			entry.MusicalKey = &traktorMusicalKey{Value: value}
+		}
+	}
+	return entry
+}
+
+// newTraktorLocation splits a path into the volume, the directory in Traktor's
+// "/:"-separated notation and the file name.
+func newTraktorLocation(path string) traktorLocation {
+	volume := filepath.VolumeName(path)
+	dir, file := filepath.Split(strings.TrimPrefix(path, volume))
+	var builder strings.Builder
+	for _, component := range strings.Split(filepath.ToSlash(dir), "/") {
+		if component == "" {
+			continue
+		}
+		builder.WriteString("/:")
+		builder.WriteString(component)
+	}
+	builder.WriteString("/:")
+	return traktorLocation{
+		Dir:    builder.String(),
+		File:   file,
+		Volume: volume,
+	}
+}
+
+func (l traktorLocation) primaryKey() string {
+	return l.Volume + l.Dir + l.File
+}
+
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
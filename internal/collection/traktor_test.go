@@ -0,0 +1,73 @@
+package collection
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestWriteTraktor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collection.nml")
+
+	key, err := beatport.ParseKey("8A", "camelot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := NewSession()
+	session.AddTrack(Track{
+		Location:    "/music/Chart/01. A - One.flac",
+		Title:       "One",
+		Artists:     "A",
+		Genre:       "Techno",
+		Label:       "Label",
+		Key:         "8A",
+		MusicalKey:  key,
+		BPM:         128,
+		ReleaseDate: "2024-05-07",
+		Size:        4096,
+	})
+	session.AddPlaylist(Playlist{Name: "Chart", Locations: []string{"/music/Chart/01. A - One.flac"}})
+	if err := WriteTraktor(path, session); err != nil {
+		t.Fatal(err)
+	}
+	// Writing the same session again must not duplicate anything
+	if err := WriteTraktor(path, session); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc traktorDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Collection.Entry) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(doc.Collection.Entry))
+	}
+	entry := doc.Collection.Entry[0]
+	if entry.Location.Dir != "/:music/:Chart/:" || entry.Location.File != "01. A - One.flac" {
+		t.Errorf("unexpected location %+v", entry.Location)
+	}
+	if entry.MusicalKey == nil || entry.MusicalKey.Value != 21 {
+		t.Errorf("expected musical key 21 (A minor), got %+v", entry.MusicalKey)
+	}
+	if entry.Tempo == nil || entry.Tempo.BPM != "128.000000" {
+		t.Errorf("unexpected tempo %+v", entry.Tempo)
+	}
+	if entry.Info.ReleaseDate != "2024/5/7" || entry.Info.Key != "8A" || entry.Info.FileSize != 4 {
+		t.Errorf("unexpected info %+v", entry.Info)
+	}
+
+	nodes := doc.Playlists.Root.Subnodes.Nodes
+	if len(nodes) != 1 || nodes[0].Name != "Chart" || nodes[0].Playlist.Entries != 1 {
+		t.Fatalf("unexpected playlists %+v", nodes)
+	}
+	if got := nodes[0].Playlist.Entry[0].PrimaryKey.Key; got != "/:music/:Chart/:01. A - One.flac" {
+		t.Errorf("unexpected primary key %q", got)
+	}
+}
@@ -0,0 +1,196 @@
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry describes a track file that has been downloaded successfully. Format
+// is the output format the download was transcoded to, empty for the
+// original file.
+type Entry struct {
+	TrackID      int64     `json:"track_id"`
+	Quality      string    `json:"quality"`
+	Format       string    `json:"format,omitempty"`
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	Checksum     string    `json:"sha256"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+type key struct {
+	trackID int64
+	quality string
+	format  string
+}
+
+// Store is an append-only JSON lines file of download entries, indexed in
+// memory by track ID, quality and format. Later entries replace earlier ones. A nil
+// Store is valid and remembers nothing.
+type Store struct {
+	mutex   sync.RWMutex
+	file    *os.File
+	entries map[key]Entry
+}
+
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("could not create folder for history file: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open history file: %w", err)
+	}
+
+	s := &Store{
+		file:    file,
+		entries: make(map[key]Entry),
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		// A line cut short by a crash is skipped instead of failing the whole file
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		s.entries[key{entry.TrackID, entry.Quality, entry.Format}] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("read history file: %w", err)
+	}
+
+	if err := terminateLastLine(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// terminateLastLine makes sure the next entry starts on its own line even if
+// the previous write was cut short.
+func terminateLastLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat history file: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return fmt.Errorf("read history file: %w", err)
+	}
+	if last[0] != '\n' {
+		if _, err := file.Write([]byte{'\n'}); err != nil {
+			return fmt.Errorf("write history file: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) Lookup(trackID int64, quality, format string) (Entry, bool) {
+	if s == nil {
+		return Entry{}, false
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	entry, found := s.entries[key{trackID, quality, format}]
+	return entry, found
+}
+
+func (s *Store) Add(entry Entry) error {
+	if s == nil {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal history entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("write history entry: %w", err)
+	}
+	s.entries[key{entry.TrackID, entry.Quality, entry.Format}] = entry
+	return nil
+}
+
+// Move points the entries recorded for the file at from to its new path.
+// Relative paths are compared by their absolute form.
+func (s *Store) Move(from, to string) error {
+	if s == nil {
+		return nil
+	}
+	from = absPath(from)
+
+	s.mutex.RLock()
+	var moved []Entry
+	for _, entry := range s.entries {
+		if absPath(entry.Path) == from {
+			entry.Path = to
+			moved = append(moved, entry)
+		}
+	}
+	s.mutex.RUnlock()
+
+	for _, entry := range moved {
+		if err := s.Add(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// NewEntry builds an entry for the file at path, recording its current size
+// and SHA-256 checksum.
+func NewEntry(trackID int64, quality, format string, path string) (Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return Entry{}, fmt.Errorf("checksum file: %w", err)
+	}
+
+	return Entry{
+		TrackID:      trackID,
+		Quality:      quality,
+		Format:       format,
+		Path:         path,
+		Size:         size,
+		Checksum:     hex.EncodeToString(hash.Sum(nil)),
+		DownloadedAt: time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,88 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "state", "history.jsonl")
+	trackPath := filepath.Join(dir, "track.flac")
+	if err := os.WriteFile(trackPath, []byte("strobe"), 0644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+
+	store, err := Open(historyPath)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	entry, err := NewEntry(1696999, "lossless", "", trackPath)
+	if err != nil {
+		t.Fatalf("NewEntry() failed: %v", err)
+	}
+	if entry.Size != 6 || len(entry.Checksum) != 64 {
+		t.Errorf("NewEntry() size = %d, checksum = %q", entry.Size, entry.Checksum)
+	}
+	if err := store.Add(entry); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	store.Close()
+
+	// Simulate a write interrupted halfway through a line.
+	f, _ := os.OpenFile(historyPath, os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"track_id":591753,"qual`)
+	f.Close()
+
+	store, err = Open(historyPath)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if err := store.Add(Entry{TrackID: 591753, Quality: "lossless", Path: "move-for-me.flac"}); err != nil {
+		t.Fatalf("Add() after truncated line failed: %v", err)
+	}
+	store.Close()
+
+	store, err = Open(historyPath)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	if _, found := store.Lookup(591753, "lossless", ""); !found {
+		t.Errorf("entry written after a truncated line was lost")
+	}
+
+	got, found := store.Lookup(1696999, "lossless", "")
+	if !found || got.Path != trackPath || got.Checksum != entry.Checksum {
+		t.Errorf("Lookup() = %+v, %v, want %+v", got, found, entry)
+	}
+	if _, found := store.Lookup(1696999, "high", ""); found {
+		t.Errorf("Lookup() found entry for a different quality")
+	}
+	if _, found := store.Lookup(1696999, "lossless", "aiff"); found {
+		t.Errorf("Lookup() found entry for a different format")
+	}
+
+	movedPath := filepath.Join(dir, "Release", "track.flac")
+	if err := store.Move(trackPath, movedPath); err != nil {
+		t.Fatalf("Move() failed: %v", err)
+	}
+	if got, _ := store.Lookup(1696999, "lossless", ""); got.Path != movedPath || got.Checksum != entry.Checksum {
+		t.Errorf("Lookup() after Move() = %+v", got)
+	}
+	if got, _ := store.Lookup(591753, "lossless", ""); got.Path != "move-for-me.flac" {
+		t.Errorf("Move() changed an unrelated entry: %+v", got)
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var store *Store
+	if _, found := store.Lookup(1, "lossless", ""); found {
+		t.Errorf("nil store found an entry")
+	}
+	if err := store.Add(Entry{TrackID: 1}); err != nil {
+		t.Errorf("nil store Add() = %v", err)
+	}
+}
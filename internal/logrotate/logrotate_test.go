@@ -0,0 +1,63 @@
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beatportdl-err.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	expected := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(name), got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more backups than maxBackups were kept")
+	}
+}
+
+func TestNoRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beatportdl-err.log")
+	f, err := Open(path, 0, 2)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	for range 10 {
+		f.Write([]byte("a line longer than nothing\n"))
+	}
+	f.Close()
+
+	got, _ := os.ReadFile(path)
+	if strings.Count(string(got), "\n") != 10 {
+		t.Errorf("log file was rotated without a size limit: %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+// Package remux repackages the AAC audio of HLS streams into an MP4 (M4A)
+// container without any external tools.
+package remux
+
+import (
+	"errors"
+)
+
+var (
+	ErrNoAudio          = errors.New("no aac frames found")
+	ErrUnsupportedAudio = errors.New("unsupported aac stream")
+)
+
+// samplesPerFrame is the number of PCM samples every AAC frame decodes to.
+const samplesPerFrame = 1024
+
+var sampleRates = [...]int{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050,
+	16000, 12000, 11025, 8000, 7350,
+}
+
+// audioConfig describes the AAC stream as found in the ADTS headers.
+type audioConfig struct {
+	objectType     int
+	frequencyIndex int
+	channels       int
+}
+
+func (c audioConfig) sampleRate() int {
+	return sampleRates[c.frequencyIndex]
+}
+
+// specificConfig returns the AudioSpecificConfig of the stream (ISO 14496-3).
+func (c audioConfig) specificConfig() []byte {
+	return []byte{
+		byte(c.objectType<<3 | c.frequencyIndex>>1),
+		byte(c.frequencyIndex&1<<7 | c.channels<<3),
+	}
+}
+
+// parseADTS splits an ADTS stream into raw AAC frames. ID3 tags, which HLS
+// packed audio puts at the start of every segment, and any garbage between
+// frames are skipped.
+func parseADTS(data []byte) (audioConfig, [][]byte, error) {
+	var config audioConfig
+	var frames [][]byte
+
+	for i := 0; i+7 <= len(data); {
+		if data[i] == 'I' && i+10 <= len(data) && data[i+1] == 'D' && data[i+2] == '3' {
+			size := int(data[i+6]&0x7f)<<21 | int(data[i+7]&0x7f)<<14 |
+				int(data[i+8]&0x7f)<<7 | int(data[i+9]&0x7f)
+			i += 10 + size
+			continue
+		}
+		if data[i] != 0xff || data[i+1]&0xf0 != 0xf0 {
+			i++
+			continue
+		}
+
+		protectionAbsent := data[i+1]&0x01 == 1
+		frameConfig := audioConfig{
+			objectType:     int(data[i+2]>>6) + 1,
+			frequencyIndex: int(data[i+2] >> 2 & 0x0f),
+			channels:       int(data[i+2]&0x01)<<2 | int(data[i+3]>>6),
+		}
+		frameLength := int(data[i+3]&0x03)<<11 | int(data[i+4])<<3 | int(data[i+5]>>5)
+		headerLength := 7
+		if !protectionAbsent {
+			headerLength = 9
+		}
+		if frameConfig.frequencyIndex >= len(sampleRates) || frameLength <= headerLength {
+			i++
+			continue
+		}
+		if i+frameLength > len(data) {
+			break
+		}
+		if data[i+6]&0x03 != 0 {
+			return config, nil, ErrUnsupportedAudio
+		}
+
+		if len(frames) == 0 {
+			config = frameConfig
+		} else if frameConfig != config {
+			return config, nil, ErrUnsupportedAudio
+		}
+		frames = append(frames, data[i+headerLength:i+frameLength])
+		i += frameLength
+	}
+
+	if len(frames) == 0 {
+		return config, nil, ErrNoAudio
+	}
+	return config, frames, nil
+}
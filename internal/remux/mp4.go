@@ -0,0 +1,279 @@
+package remux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// box is an MP4 box that is assembled in memory. The audio itself is never
+// copied into a box, mdat is written straight from the frames.
+type box struct {
+	bytes.Buffer
+}
+
+func (b *box) u8(v uint8)   { b.WriteByte(v) }
+func (b *box) u16(v uint16) { binary.Write(b, binary.BigEndian, v) }
+func (b *box) u32(v uint32) { binary.Write(b, binary.BigEndian, v) }
+func (b *box) zeros(n int)  { b.Write(make([]byte, n)) }
+
+// fullHeader writes the version and flags of a full box.
+func (b *box) fullHeader(version uint8, flags uint32) {
+	b.u32(uint32(version)<<24 | flags)
+}
+
+func (b *box) child(boxType string, children ...[]byte) {
+	b.Write(newBox(boxType, children...))
+}
+
+func newBox(boxType string, children ...[]byte) []byte {
+	size := 8
+	for _, child := range children {
+		size += len(child)
+	}
+	out := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(out, uint32(size))
+	copy(out[4:], boxType)
+	for _, child := range children {
+		out = append(out, child...)
+	}
+	return out
+}
+
+// unityMatrix is the identity transformation of mvhd and tkhd.
+var unityMatrix = []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000}
+
+// Clip selects the part of the audio to keep, relative to the start of the
+// input. A zero End keeps everything after Start. The cut is made on AAC frame
+// boundaries, i.e. with a precision of about 23 ms at 44.1 kHz.
+type Clip struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func (c Clip) frames(frames [][]byte, sampleRate int) [][]byte {
+	frameIndex := func(d time.Duration) int {
+		return int(d.Seconds()*float64(sampleRate)/samplesPerFrame + 0.5)
+	}
+	first := min(max(frameIndex(c.Start), 0), len(frames))
+	last := len(frames)
+	if c.End > 0 {
+		last = min(max(frameIndex(c.End), first), len(frames))
+	}
+	return frames[first:last]
+}
+
+// WriteM4A writes the AAC audio of an MPEG transport stream or an ADTS stream
+// (such as the concatenated, decrypted segments of an HLS stream) to w as an
+// M4A file, keeping only the given clip of it.
+func WriteM4A(w io.Writer, data []byte, clip Clip) error {
+	if isTransportStream(data) {
+		audio, err := demuxTransportStream(data)
+		if err != nil {
+			return err
+		}
+		data = audio
+	}
+
+	config, frames, err := parseADTS(data)
+	if err != nil {
+		return err
+	}
+	frames = clip.frames(frames, config.sampleRate())
+	if len(frames) == 0 {
+		return ErrNoAudio
+	}
+
+	var mdatSize uint32 = 8
+	for _, frame := range frames {
+		mdatSize += uint32(len(frame))
+	}
+
+	ftyp := newBox("ftyp", []byte("M4A \x00\x00\x00\x00M4A mp42isom"))
+	// The chunk offset depends on the size of moov itself, which does not
+	// change with the value of the offset, so it is built twice
+	moov := buildMoov(config, frames, 0)
+	moov = buildMoov(config, frames, uint32(len(ftyp)+len(moov)+8))
+
+	mdatHeader := make([]byte, 8)
+	binary.BigEndian.PutUint32(mdatHeader, mdatSize)
+	copy(mdatHeader[4:], "mdat")
+
+	for _, part := range [][]byte{ftyp, moov, mdatHeader} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	for _, frame := range frames {
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildMoov(config audioConfig, frames [][]byte, chunkOffset uint32) []byte {
+	timescale := uint32(config.sampleRate())
+	duration := uint32(len(frames) * samplesPerFrame)
+
+	var maxFrame, totalSize int
+	for _, frame := range frames {
+		totalSize += len(frame)
+		maxFrame = max(maxFrame, len(frame))
+	}
+	avgBitrate := uint32(uint64(totalSize) * 8 * uint64(timescale) / uint64(duration))
+
+	mvhd := &box{}
+	mvhd.fullHeader(0, 0)
+	mvhd.u32(0) // creation time
+	mvhd.u32(0) // modification time
+	mvhd.u32(timescale)
+	mvhd.u32(duration)
+	mvhd.u32(0x00010000) // rate
+	mvhd.u16(0x0100)     // volume
+	mvhd.zeros(10)
+	for _, v := range unityMatrix {
+		mvhd.u32(v)
+	}
+	mvhd.zeros(24)
+	mvhd.u32(2) // next track ID
+
+	tkhd := &box{}
+	tkhd.fullHeader(0, 0x000003) // enabled, in movie
+	tkhd.u32(0)
+	tkhd.u32(0)
+	tkhd.u32(1) // track ID
+	tkhd.zeros(4)
+	tkhd.u32(duration)
+	tkhd.zeros(8)
+	tkhd.u16(0)      // layer
+	tkhd.u16(1)      // alternate group
+	tkhd.u16(0x0100) // volume
+	tkhd.zeros(2)
+	for _, v := range unityMatrix {
+		tkhd.u32(v)
+	}
+	tkhd.u32(0) // width
+	tkhd.u32(0) // height
+
+	mdhd := &box{}
+	mdhd.fullHeader(0, 0)
+	mdhd.u32(0)
+	mdhd.u32(0)
+	mdhd.u32(timescale)
+	mdhd.u32(duration)
+	mdhd.u16(0x55c4) // "und"
+	mdhd.u16(0)
+
+	hdlr := &box{}
+	hdlr.fullHeader(0, 0)
+	hdlr.u32(0)
+	hdlr.WriteString("soun")
+	hdlr.zeros(12)
+	hdlr.WriteString("SoundHandler\x00")
+
+	smhd := &box{}
+	smhd.fullHeader(0, 0)
+	smhd.zeros(4)
+
+	url := &box{}
+	url.fullHeader(0, 1) // media data is in this file
+	dref := &box{}
+	dref.fullHeader(0, 0)
+	dref.u32(1)
+	dref.child("url ", url.Bytes())
+
+	stbl := &box{}
+	stbl.child("stsd", stsd(config, maxFrame, avgBitrate))
+
+	stts := &box{}
+	stts.fullHeader(0, 0)
+	stts.u32(1)
+	stts.u32(uint32(len(frames)))
+	stts.u32(samplesPerFrame)
+	stbl.child("stts", stts.Bytes())
+
+	stsc := &box{}
+	stsc.fullHeader(0, 0)
+	stsc.u32(1)
+	stsc.u32(1) // first chunk
+	stsc.u32(uint32(len(frames)))
+	stsc.u32(1) // sample description index
+	stbl.child("stsc", stsc.Bytes())
+
+	stsz := &box{}
+	stsz.fullHeader(0, 0)
+	stsz.u32(0) // sizes differ per sample
+	stsz.u32(uint32(len(frames)))
+	for _, frame := range frames {
+		stsz.u32(uint32(len(frame)))
+	}
+	stbl.child("stsz", stsz.Bytes())
+
+	stco := &box{}
+	stco.fullHeader(0, 0)
+	stco.u32(1)
+	stco.u32(chunkOffset)
+	stbl.child("stco", stco.Bytes())
+
+	minf := newBox("minf",
+		newBox("smhd", smhd.Bytes()),
+		newBox("dinf", newBox("dref", dref.Bytes())),
+		newBox("stbl", stbl.Bytes()),
+	)
+	mdia := newBox("mdia",
+		newBox("mdhd", mdhd.Bytes()),
+		newBox("hdlr", hdlr.Bytes()),
+		minf,
+	)
+	trak := newBox("trak", newBox("tkhd", tkhd.Bytes()), mdia)
+	return newBox("moov", newBox("mvhd", mvhd.Bytes()), trak)
+}
+
+func stsd(config audioConfig, maxFrame int, avgBitrate uint32) []byte {
+	specificConfig := config.specificConfig()
+
+	decoderConfig := &box{}
+	decoderConfig.u8(0x40) // MPEG-4 audio
+	decoderConfig.u8(0x15) // audio stream
+	decoderConfig.u8(uint8(maxFrame >> 16))
+	decoderConfig.u16(uint16(maxFrame))
+	decoderConfig.u32(avgBitrate) // max bitrate
+	decoderConfig.u32(avgBitrate)
+	decoderConfig.Write(descriptor(0x05, specificConfig))
+
+	esDescriptor := &box{}
+	esDescriptor.u16(0) // ES ID
+	esDescriptor.u8(0)  // flags
+	esDescriptor.Write(descriptor(0x04, decoderConfig.Bytes()))
+	esDescriptor.Write(descriptor(0x06, []byte{0x02}))
+
+	esds := &box{}
+	esds.fullHeader(0, 0)
+	esds.Write(descriptor(0x03, esDescriptor.Bytes()))
+
+	mp4a := &box{}
+	mp4a.zeros(6)
+	mp4a.u16(1) // data reference index
+	mp4a.zeros(8)
+	mp4a.u16(uint16(config.channels))
+	mp4a.u16(16) // sample size
+	mp4a.zeros(4)
+	mp4a.u32(uint32(config.sampleRate()) << 16)
+	mp4a.child("esds", esds.Bytes())
+
+	entries := &box{}
+	entries.fullHeader(0, 0)
+	entries.u32(1)
+	entries.child("mp4a", mp4a.Bytes())
+	return entries.Bytes()
+}
+
+// descriptor encodes an MPEG-4 descriptor with a four byte length, which
+// every decoder accepts regardless of the actual size.
+func descriptor(tag byte, payload []byte) []byte {
+	n := len(payload)
+	out := []byte{tag, byte(n>>21&0x7f | 0x80), byte(n>>14&0x7f | 0x80), byte(n>>7&0x7f | 0x80), byte(n & 0x7f)}
+	return append(out, payload...)
+}
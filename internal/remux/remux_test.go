@@ -0,0 +1,204 @@
+package remux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// adtsFrame builds an ADTS frame of AAC LC, 44.1 kHz, stereo.
+func adtsFrame(payload []byte) []byte {
+	length := len(payload) + 7
+	header := []byte{
+		0xff, 0xf1,
+		1<<6 | 4<<2, // LC, 44100 Hz
+		2<<6 | byte(length>>11),
+		byte(length >> 3),
+		byte(length<<5) | 0x1f,
+		0xfc,
+	}
+	return append(header, payload...)
+}
+
+func testFrames() ([][]byte, []byte) {
+	var frames [][]byte
+	var stream []byte
+	for i := 0; i < 50; i++ {
+		payload := bytes.Repeat([]byte{byte(i)}, 100+i)
+		frames = append(frames, payload)
+		stream = append(stream, adtsFrame(payload)...)
+	}
+	return frames, stream
+}
+
+// transportStream wraps an elementary stream into a minimal PAT, PMT and
+// PES packets, as found in HLS segments.
+func transportStream(es []byte) []byte {
+	packet := func(pid int, unitStart bool, payload []byte) []byte {
+		p := make([]byte, tsPacketSize)
+		p[0] = tsSyncByte
+		p[1] = byte(pid >> 8 & 0x1f)
+		if unitStart {
+			p[1] |= 0x40
+		}
+		p[2] = byte(pid)
+		if len(payload) >= tsPacketSize-4 {
+			p[3] = 0x10
+			copy(p[4:], payload)
+			return p
+		}
+		// Pad short payloads with an adaptation field of stuffing bytes
+		p[3] = 0x30
+		stuffing := tsPacketSize - 4 - len(payload) - 1
+		p[4] = byte(stuffing)
+		for i := 0; i < stuffing; i++ {
+			p[5+i] = 0xff
+		}
+		if stuffing > 0 {
+			p[5] = 0
+		}
+		copy(p[5+stuffing:], payload)
+		return p
+	}
+
+	pat := []byte{0, 0x00, 0xb0, 13, 0, 1, 0xc1, 0, 0, 0, 1, 0xe1, 0x00, 0, 0, 0, 0}
+	pmt := []byte{0, 0x02, 0xb0, 18, 0, 1, 0xc1, 0, 0, 0xe1, 0x01, 0xf0, 0, streamTypeADTS, 0xe1, 0x01, 0xf0, 0, 0, 0, 0, 0}
+
+	out := append(packet(0, true, pat), packet(0x100, true, pmt)...)
+	pes := append([]byte{0, 0, 1, 0xc0, 0, 0, 0x80, 0x80, 5, 0x21, 0, 1, 0, 1}, es...)
+	for first := true; len(pes) > 0; first = false {
+		n := min(len(pes), tsPacketSize-4)
+		out = append(out, packet(0x101, first, pes[:n])...)
+		pes = pes[n:]
+	}
+	return out
+}
+
+func findBox(data []byte, path ...string) []byte {
+	for _, name := range path {
+		found := false
+		for len(data) >= 8 {
+			size := int(binary.BigEndian.Uint32(data))
+			if size < 8 || size > len(data) {
+				return nil
+			}
+			if string(data[4:8]) == name {
+				data = data[8:size]
+				found = true
+				break
+			}
+			data = data[size:]
+		}
+		if !found {
+			return nil
+		}
+	}
+	return data
+}
+
+func TestParseADTS(t *testing.T) {
+	frames, stream := testFrames()
+	// ID3 tags of packed audio segments must be skipped
+	id3 := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 3, 1, 2, 3}
+	data := append(append([]byte{}, id3...), stream...)
+
+	config, parsed, err := parseADTS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.objectType != 2 || config.sampleRate() != 44100 || config.channels != 2 {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if len(parsed) != len(frames) {
+		t.Fatalf("expected %d frames, got %d", len(frames), len(parsed))
+	}
+	for i := range frames {
+		if !bytes.Equal(parsed[i], frames[i]) {
+			t.Fatalf("frame %d differs", i)
+		}
+	}
+	if asc := config.specificConfig(); !bytes.Equal(asc, []byte{0x12, 0x10}) {
+		t.Errorf("unexpected AudioSpecificConfig %x", asc)
+	}
+}
+
+func TestWriteM4A(t *testing.T) {
+	frames, stream := testFrames()
+
+	for name, input := range map[string][]byte{
+		"adts": stream,
+		"ts":   transportStream(stream),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WriteM4A(&out, input, Clip{}); err != nil {
+				t.Fatal(err)
+			}
+			data := out.Bytes()
+
+			if ftyp := findBox(data, "ftyp"); ftyp == nil || string(ftyp[:4]) != "M4A " {
+				t.Fatal("missing ftyp")
+			}
+			stbl := findBox(data, "moov", "trak", "mdia", "minf", "stbl")
+			if stbl == nil {
+				t.Fatal("missing stbl")
+			}
+			stsz := findBox(stbl, "stsz")
+			if count := binary.BigEndian.Uint32(stsz[8:]); int(count) != len(frames) {
+				t.Fatalf("expected %d samples, got %d", len(frames), count)
+			}
+			if esds := findBox(stbl, "stsd"); !bytes.Contains(esds, []byte{0x05, 0x80, 0x80, 0x80, 2, 0x12, 0x10}) {
+				t.Error("missing AudioSpecificConfig")
+			}
+
+			offset := int(binary.BigEndian.Uint32(findBox(stbl, "stco")[8:]))
+			for i, frame := range frames {
+				size := int(binary.BigEndian.Uint32(stsz[12+4*i:]))
+				if !bytes.Equal(data[offset:offset+size], frame) {
+					t.Fatalf("sample %d differs", i)
+				}
+				offset += size
+			}
+			if offset != len(data) {
+				t.Errorf("samples end at %d, file ends at %d", offset, len(data))
+			}
+			if mdat := findBox(data, "mdat"); mdat == nil {
+				t.Error("missing mdat")
+			}
+
+			mdhd := findBox(data, "moov", "trak", "mdia", "mdhd")
+			if timescale := binary.BigEndian.Uint32(mdhd[12:]); timescale != 44100 {
+				t.Errorf("unexpected timescale %d", timescale)
+			}
+			if duration := binary.BigEndian.Uint32(mdhd[16:]); duration != uint32(len(frames)*samplesPerFrame) {
+				t.Errorf("unexpected duration %d", duration)
+			}
+		})
+	}
+}
+
+func TestClipFrames(t *testing.T) {
+	frames := make([][]byte, 100)
+	for i := range frames {
+		frames[i] = []byte{byte(i)}
+	}
+	frameDuration := time.Second * samplesPerFrame / 44100
+
+	cases := []struct {
+		clip        Clip
+		first, last int
+	}{
+		{Clip{}, 0, 100},
+		{Clip{Start: 10 * frameDuration}, 10, 100},
+		{Clip{Start: 10 * frameDuration, End: 20 * frameDuration}, 10, 20},
+		{Clip{End: time.Hour}, 0, 100},
+		{Clip{Start: time.Hour}, 100, 100},
+	}
+	for _, c := range cases {
+		got := c.clip.frames(frames, 44100)
+		if len(got) != c.last-c.first || (len(got) > 0 && int(got[0][0]) != c.first) {
+			t.Errorf("%+v: expected frames %d-%d, got %d frames", c.clip, c.first, c.last, len(got))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package remux
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+
+	streamTypeADTS = 0x0f
+)
+
+// isTransportStream reports whether data looks like an MPEG transport stream.
+func isTransportStream(data []byte) bool {
+	if len(data) < tsPacketSize || data[0] != tsSyncByte {
+		return false
+	}
+	return len(data) < 2*tsPacketSize || data[tsPacketSize] == tsSyncByte
+}
+
+// demuxTransportStream extracts the elementary stream of the first ADTS AAC
+// track of an MPEG transport stream.
+func demuxTransportStream(data []byte) ([]byte, error) {
+	pmtPID := -1
+	audioPID := -1
+	var audio []byte
+
+	for offset := 0; offset+tsPacketSize <= len(data); offset += tsPacketSize {
+		packet := data[offset : offset+tsPacketSize]
+		if packet[0] != tsSyncByte {
+			return nil, ErrUnsupportedAudio
+		}
+		unitStart := packet[1]&0x40 != 0
+		pid := int(packet[1]&0x1f)<<8 | int(packet[2])
+		adaptation := packet[3] >> 4 & 0x03
+
+		payload := packet[4:]
+		if adaptation&0x02 != 0 {
+			if len(payload) == 0 || int(payload[0])+1 > len(payload) {
+				continue
+			}
+			payload = payload[int(payload[0])+1:]
+		}
+		if adaptation&0x01 == 0 {
+			continue
+		}
+
+		switch {
+		case pid == 0 && unitStart:
+			if section := psiSection(payload); len(section) >= 12 {
+				// The first program of the PAT is the only one HLS streams carry
+				pmtPID = int(section[10]&0x1f)<<8 | int(section[11])
+			}
+		case pid == pmtPID && unitStart && audioPID < 0:
+			audioPID = parsePMT(psiSection(payload))
+		case pid == audioPID:
+			if unitStart {
+				payload = pesPayload(payload)
+			}
+			audio = append(audio, payload...)
+		}
+	}
+
+	if audioPID < 0 {
+		return nil, ErrNoAudio
+	}
+	return audio, nil
+}
+
+// psiSection skips the pointer field of a PSI payload.
+func psiSection(payload []byte) []byte {
+	if len(payload) == 0 || int(payload[0])+1 > len(payload) {
+		return nil
+	}
+	return payload[int(payload[0])+1:]
+}
+
+// parsePMT returns the PID of the first ADTS AAC stream of a program map
+// section, or -1.
+func parsePMT(section []byte) int {
+	if len(section) < 12 {
+		return -1
+	}
+	sectionLength := int(section[1]&0x0f)<<8 | int(section[2])
+	end := 3 + sectionLength - 4
+	if end > len(section) {
+		end = len(section)
+	}
+	programInfoLength := int(section[10]&0x0f)<<8 | int(section[11])
+	for i := 12 + programInfoLength; i+5 <= end; {
+		streamType := section[i]
+		pid := int(section[i+1]&0x1f)<<8 | int(section[i+2])
+		infoLength := int(section[i+3]&0x0f)<<8 | int(section[i+4])
+		if streamType == streamTypeADTS {
+			return pid
+		}
+		i += 5 + infoLength
+	}
+	return -1
+}
+
+// pesPayload skips the PES header at the start of a payload unit.
+func pesPayload(payload []byte) []byte {
+	if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return nil
+	}
+	headerEnd := 9 + int(payload[8])
+	if headerEnd > len(payload) {
+		return nil
+	}
+	return payload[headerEnd:]
+}
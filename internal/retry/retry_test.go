@@ -0,0 +1,120 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDoRetriesTransientErrors(t *testing.T) {
+	var retries []int
+	p := &Policy{
+		MaxAttempts: 3,
+		OnRetry: func(target string, attempt int, delay time.Duration, err error) {
+			retries = append(retries, attempt)
+		},
+	}
+
+	calls := 0
+	err := p.Do(context.Background(), "test", func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do() = %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+	if len(retries) != 2 {
+		t.Errorf("OnRetry called %d times, want 2", len(retries))
+	}
+}
+
+func TestDoStopsOnPermanentErrors(t *testing.T) {
+	p := &Policy{MaxAttempts: 5}
+	want := errors.New("create file")
+
+	calls := 0
+	err := p.Do(context.Background(), "test", func() error {
+		calls++
+		return Permanent(want)
+	})
+	if err != want {
+		t.Fatalf("Do() = %v, want %v", err, want)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), "test", func() error {
+		calls++
+		return &StatusError{StatusCode: http.StatusNotFound, Err: errors.New("not found")}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Do() with 404 = %v after %d calls, want error after 1 call", err, calls)
+	}
+}
+
+func TestNilPolicyRunsOnce(t *testing.T) {
+	var p *Policy
+	calls := 0
+	err := p.Do(context.Background(), "test", func() error {
+		calls++
+		return errors.New("timeout")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want error after 1 call", err, calls)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	p := &Policy{BaseDelay: time.Second, MaxDelay: 4 * time.Second}
+
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 5: 4 * time.Second} {
+		delay := p.Delay(attempt, errors.New("timeout"))
+		if delay < max/2 || delay > max {
+			t.Errorf("Delay(%d) = %s, want between %s and %s", attempt, delay, max/2, max)
+		}
+	}
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"3"}}}
+	if delay := p.Delay(1, NewStatusError(resp, errors.New("rate limited"))); delay != 3*time.Second {
+		t.Errorf("Delay() with Retry-After = %s, want 3s", delay)
+	}
+
+	resp = &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"86400"}}}
+	if delay := p.Delay(1, NewStatusError(resp, errors.New("unavailable"))); delay != p.MaxDelay {
+		t.Errorf("Delay() with Retry-After = %s, want it capped at %s", delay, p.MaxDelay)
+	}
+	uncapped := &Policy{BaseDelay: time.Second}
+	if delay := uncapped.Delay(1, NewStatusError(resp, errors.New("unavailable"))); delay != 24*time.Hour {
+		t.Errorf("Delay() without MaxDelay = %s, want the Retry-After", delay)
+	}
+}
+
+func TestDoStopsWhenContextIsDone(t *testing.T) {
+	p := &Policy{MaxAttempts: 5, BaseDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.OnRetry = func(target string, attempt int, delay time.Duration, err error) {
+		cancel()
+	}
+
+	calls := 0
+	err := p.Do(ctx, "test", func() error {
+		calls++
+		return errors.New("timeout")
+	})
+	if err != context.Canceled {
+		t.Fatalf("Do() = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
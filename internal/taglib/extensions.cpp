@@ -3,6 +3,14 @@
 #include <taglib/mp4file.h>
 #include <taglib/mp4tag.h>
 #include <taglib/tstring.h>
+#include <taglib/mpegfile.h>
+#include <taglib/aifffile.h>
+#include <taglib/wavfile.h>
+#include <taglib/id3v2tag.h>
+#include <taglib/textidentificationframe.h>
+#include <taglib/attachedpictureframe.h>
+#include <cstdlib>
+#include <cstring>
 #include <string>
 #include <locale>
 #include <codecvt>
@@ -32,6 +40,35 @@ void taglib_set_item_mp4(TagLib_File *file, const char *key, const char *value)
     }
 }
 
+char *taglib_get_item_mp4(TagLib_File *file, const char *key) {
+    if(file == NULL || key == NULL)
+        return NULL;
+    TagLib::MP4::File *mfile = dynamic_cast<TagLib::MP4::File *>(reinterpret_cast<TagLib::FileRef *>(file)->file());
+    TagLib::MP4::Tag *tag = mfile->tag();
+    if(!tag)
+        return NULL;
+    TagLib::String tagKey(BASE_MP4_ATOM);
+    tagKey.append(TagLib::String(key));
+    if(!tag->contains(tagKey))
+        return NULL;
+    TagLib::StringList values = tag->item(tagKey).toStringList();
+    if(values.size() == 0)
+        return NULL;
+    return strdup(values[0].toCString(true));
+}
+
+void taglib_remove_item_mp4(TagLib_File *file, const char *key) {
+    if(file == NULL || key == NULL)
+        return;
+    TagLib::MP4::File *mfile = dynamic_cast<TagLib::MP4::File *>(reinterpret_cast<TagLib::FileRef *>(file)->file());
+    TagLib::MP4::Tag *tag = mfile->tag();
+    if(tag) {
+        TagLib::String tagKey(BASE_MP4_ATOM);
+        tagKey.append(TagLib::String(key));
+        tag->removeItem(tagKey);
+    }
+}
+
 int taglib_strip_mp4(TagLib_File *file) {
     if(file == NULL)
         return 0;
@@ -43,4 +80,128 @@ int taglib_strip_mp4(TagLib_File *file) {
 void taglib_set_picture(TagLib_File *file, const char *data, unsigned int size, const char *desc, const char *mime, const char *typ) {
     TAGLIB_COMPLEX_PROPERTY_PICTURE(props, data, size, desc, mime, typ);
     taglib_complex_property_set(file, "PICTURE", props);
-}
\ No newline at end of file
+}
+
+static TagLib::ID3v2::Tag *id3v2_tag(TagLib_File *file) {
+    if(file == NULL)
+        return NULL;
+    TagLib::File *f = reinterpret_cast<TagLib::FileRef *>(file)->file();
+    if(TagLib::MPEG::File *mpeg = dynamic_cast<TagLib::MPEG::File *>(f))
+        return mpeg->ID3v2Tag(true);
+    if(TagLib::RIFF::AIFF::File *aiff = dynamic_cast<TagLib::RIFF::AIFF::File *>(f))
+        return aiff->tag();
+    if(TagLib::RIFF::WAV::File *wav = dynamic_cast<TagLib::RIFF::WAV::File *>(f))
+        return wav->ID3v2Tag();
+    return NULL;
+}
+
+// "TXXX:<description>" selects the user text frame with that description,
+// any other id must be a four character text frame id such as "TBPM".
+static bool is_user_text_frame(const std::string &id) {
+    return id.rfind("TXXX:", 0) == 0;
+}
+
+int taglib_id3v2_clear(TagLib_File *file) {
+    TagLib::ID3v2::Tag *tag = id3v2_tag(file);
+    if(!tag)
+        return 0;
+    TagLib::ID3v2::FrameList frames = tag->frameList();
+    for(TagLib::ID3v2::FrameList::ConstIterator it = frames.begin(); it != frames.end(); ++it)
+        tag->removeFrame(*it, true);
+    return 1;
+}
+
+int taglib_id3v2_set_frame(TagLib_File *file, const char *id, const char *value) {
+    TagLib::ID3v2::Tag *tag = id3v2_tag(file);
+    if(!tag || id == NULL || value == NULL)
+        return 0;
+    std::string frameId(id);
+    TagLib::String text(value, TagLib::String::UTF8);
+
+    if(is_user_text_frame(frameId)) {
+        TagLib::String description(frameId.substr(5), TagLib::String::UTF8);
+        TagLib::ID3v2::UserTextIdentificationFrame *existing =
+            TagLib::ID3v2::UserTextIdentificationFrame::find(tag, description);
+        if(existing)
+            tag->removeFrame(existing, true);
+        TagLib::ID3v2::UserTextIdentificationFrame *frame =
+            new TagLib::ID3v2::UserTextIdentificationFrame(TagLib::String::UTF8);
+        frame->setDescription(description);
+        frame->setText(text);
+        tag->addFrame(frame);
+        return 1;
+    }
+
+    if(frameId.size() != 4 || frameId[0] != 'T')
+        return 0;
+    TagLib::ByteVector frameIdBytes(frameId.c_str(), 4);
+    tag->removeFrames(frameIdBytes);
+    TagLib::ID3v2::TextIdentificationFrame *frame =
+        new TagLib::ID3v2::TextIdentificationFrame(frameIdBytes, TagLib::String::UTF8);
+    frame->setText(text);
+    tag->addFrame(frame);
+    return 1;
+}
+
+int taglib_id3v2_remove_frame(TagLib_File *file, const char *id) {
+    TagLib::ID3v2::Tag *tag = id3v2_tag(file);
+    if(!tag || id == NULL)
+        return 0;
+    std::string frameId(id);
+
+    if(is_user_text_frame(frameId)) {
+        TagLib::String description(frameId.substr(5), TagLib::String::UTF8);
+        TagLib::ID3v2::UserTextIdentificationFrame *existing =
+            TagLib::ID3v2::UserTextIdentificationFrame::find(tag, description);
+        if(existing)
+            tag->removeFrame(existing, true);
+        return 1;
+    }
+
+    if(frameId.size() != 4 || frameId[0] != 'T')
+        return 0;
+    tag->removeFrames(TagLib::ByteVector(frameId.c_str(), 4));
+    return 1;
+}
+
+char *taglib_id3v2_get_frame(TagLib_File *file, const char *id) {
+    TagLib::ID3v2::Tag *tag = id3v2_tag(file);
+    if(!tag || id == NULL)
+        return NULL;
+    std::string frameId(id);
+    TagLib::String value;
+
+    if(is_user_text_frame(frameId)) {
+        TagLib::ID3v2::UserTextIdentificationFrame *frame = TagLib::ID3v2::UserTextIdentificationFrame::find(
+            tag, TagLib::String(frameId.substr(5), TagLib::String::UTF8));
+        if(!frame)
+            return NULL;
+        TagLib::StringList fields = frame->fieldList();
+        // The first field is the description
+        if(fields.size() > 1)
+            value = fields[1];
+    } else {
+        if(frameId.size() != 4)
+            return NULL;
+        const TagLib::ID3v2::FrameList &frames = tag->frameList(TagLib::ByteVector(frameId.c_str(), 4));
+        if(frames.isEmpty())
+            return NULL;
+        value = frames.front()->toString();
+    }
+    return strdup(value.toCString(true));
+}
+
+int taglib_id3v2_set_picture(TagLib_File *file, const char *data, unsigned int size, const char *desc, const char *mime) {
+    TagLib::ID3v2::Tag *tag = id3v2_tag(file);
+    if(!tag || data == NULL)
+        return 0;
+    tag->removeFrames("APIC");
+    TagLib::ID3v2::AttachedPictureFrame *frame = new TagLib::ID3v2::AttachedPictureFrame();
+    frame->setTextEncoding(TagLib::String::UTF8);
+    frame->setMimeType(TagLib::String(mime, TagLib::String::UTF8));
+    frame->setDescription(TagLib::String(desc, TagLib::String::UTF8));
+    frame->setType(TagLib::ID3v2::AttachedPictureFrame::FrontCover);
+    frame->setPicture(TagLib::ByteVector(data, size));
+    tag->addFrame(frame);
+    return 1;
+}
@@ -9,8 +9,15 @@ extern "C" {
 
 TagLib_File *taglib_file_new_wide(const char *filename);
 void taglib_set_item_mp4(TagLib_File *file, const char *key, const char *value);
+char *taglib_get_item_mp4(TagLib_File *file, const char *key);
+void taglib_remove_item_mp4(TagLib_File *file, const char *key);
 int taglib_strip_mp4(TagLib_File *file);
 void taglib_set_picture(TagLib_File *file, const char *data, unsigned int size, const char *desc, const char *mime, const char *typ);
+int taglib_id3v2_clear(TagLib_File *file);
+int taglib_id3v2_set_frame(TagLib_File *file, const char *id, const char *value);
+int taglib_id3v2_remove_frame(TagLib_File *file, const char *id);
+char *taglib_id3v2_get_frame(TagLib_File *file, const char *id);
+int taglib_id3v2_set_picture(TagLib_File *file, const char *data, unsigned int size, const char *desc, const char *mime);
 
 #ifdef __cplusplus
 }
@@ -10,6 +10,7 @@ import "C"
 
 import (
 	"errors"
+	"fmt"
 	"unsafe"
 )
 
@@ -18,6 +19,8 @@ var (
 	ErrStripMp4  = errors.New("cannot strip mp4 tags")
 	ErrSave      = errors.New("cannot save file")
 	ErrNoPicture = errors.New("no picture")
+	ErrNoID3v2   = errors.New("file has no id3v2 tag")
+	ErrSetFrame  = errors.New("cannot set id3v2 frame")
 )
 
 func init() {
@@ -68,6 +71,24 @@ func (f *File) SetItemMp4(key, value string) {
 	C.taglib_set_item_mp4(f.fp, keyC, valueC)
 }
 
+// GetItemMp4 returns the value of a freeform item written by SetItemMp4, or
+// an empty string if the file doesn't have it.
+func (f *File) GetItemMp4(key string) string {
+	keyC := C.CString(key)
+	defer C.free(unsafe.Pointer(keyC))
+	valueC := C.taglib_get_item_mp4(f.fp, keyC)
+	if valueC == nil {
+		return ""
+	}
+	return convertAndFree(valueC)
+}
+
+func (f *File) RemoveItemMp4(key string) {
+	keyC := C.CString(key)
+	defer C.free(unsafe.Pointer(keyC))
+	C.taglib_remove_item_mp4(f.fp, keyC)
+}
+
 func (f *File) StripMp4() error {
 	valueC := C.taglib_strip_mp4(f.fp)
 	if int(valueC) != 1 {
@@ -76,6 +97,59 @@ func (f *File) StripMp4() error {
 	return nil
 }
 
+// ID3v2 API, for MP3, AIFF and WAV files. Frames are addressed by their
+// four character ID, user text frames by "TXXX:<description>".
+func (f *File) ClearID3v2() error {
+	if C.taglib_id3v2_clear(f.fp) != 1 {
+		return ErrNoID3v2
+	}
+	return nil
+}
+
+func (f *File) SetID3v2Frame(id, value string) error {
+	idC := getCCharPointer(id)
+	defer C.free(unsafe.Pointer(idC))
+	valueC := getCCharPointer(value)
+	defer C.free(unsafe.Pointer(valueC))
+	if C.taglib_id3v2_set_frame(f.fp, idC, valueC) != 1 {
+		return fmt.Errorf("%w: %s", ErrSetFrame, id)
+	}
+	return nil
+}
+
+func (f *File) RemoveID3v2Frame(id string) error {
+	idC := getCCharPointer(id)
+	defer C.free(unsafe.Pointer(idC))
+	if C.taglib_id3v2_remove_frame(f.fp, idC) != 1 {
+		return fmt.Errorf("%w: %s", ErrSetFrame, id)
+	}
+	return nil
+}
+
+func (f *File) GetID3v2Frame(id string) string {
+	idC := getCCharPointer(id)
+	defer C.free(unsafe.Pointer(idC))
+	valueC := C.taglib_id3v2_get_frame(f.fp, idC)
+	if valueC == nil {
+		return ""
+	}
+	return convertAndFree(valueC)
+}
+
+func (f *File) SetID3v2Picture(picture *Picture) error {
+	dataC := C.CBytes(picture.Data)
+	defer C.free(dataC)
+	descC := C.CString(picture.Description)
+	defer C.free(unsafe.Pointer(descC))
+	mimeC := C.CString(picture.MimeType)
+	defer C.free(unsafe.Pointer(mimeC))
+
+	if C.taglib_id3v2_set_picture(f.fp, (*C.char)(dataC), C.uint(picture.Size), descC, mimeC) != 1 {
+		return ErrNoID3v2
+	}
+	return nil
+}
+
 // Properties API
 func (f *File) GetProperty(property string) string {
 	propertyC := C.CString(property)
@@ -118,6 +192,11 @@ func (f *File) SampleRate() int {
 	return int(C.taglib_audioproperties_samplerate(f.props))
 }
 
+// Length returns the duration of the audio in seconds.
+func (f *File) Length() int {
+	return int(C.taglib_audioproperties_length(f.props))
+}
+
 // Complex Properties API
 type Picture struct {
 	MimeType    string
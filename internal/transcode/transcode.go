@@ -0,0 +1,71 @@
+// Package transcode converts downloaded audio files to other formats.
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var (
+	ErrEncoderNotFound   = errors.New("no encoder found")
+	ErrUnsupportedFormat = errors.New("unsupported output format")
+)
+
+// Encoder converts the audio of input to the format of the given file
+// extension (without the dot) and writes it to output. Only the audio is
+// kept, tags and pictures are written afterwards.
+type Encoder interface {
+	Encode(ctx context.Context, input, output, format string) error
+}
+
+// FFmpeg is an Encoder running the ffmpeg binary.
+type FFmpeg struct {
+	Path string
+}
+
+// ffmpegCodecArgs are the ffmpeg arguments selecting the codec of a format.
+var ffmpegCodecArgs = map[string][]string{
+	"mp3":  {"-c:a", "libmp3lame", "-b:a", "320k"},
+	"aiff": {"-c:a", "pcm_s16be"},
+	"wav":  {"-c:a", "pcm_s16le"},
+}
+
+// NewFFmpeg looks up ffmpeg in PATH.
+func NewFFmpeg() (*FFmpeg, error) {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("%w: ffmpeg is not installed", ErrEncoderNotFound)
+	}
+	return &FFmpeg{Path: path}, nil
+}
+
+func (f *FFmpeg) Encode(ctx context.Context, input, output, format string) error {
+	codecArgs, ok := ffmpegCodecArgs[format]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	args := []string{
+		"-y",
+		"-v", "error",
+		"-i", input,
+		"-map", "0:a",
+		"-map_metadata", "-1",
+	}
+	args = append(args, codecArgs...)
+	args = append(args, "-f", format, output)
+
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, f.Path, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("ffmpeg: %w: %s", err, message)
+		}
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	return nil
+}